@@ -1,9 +1,13 @@
 package jsonrpc
 
 import (
+	"context"
 	"database/sql"
 	"net"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Base is the base for both request and response structures
@@ -23,14 +27,25 @@ type Error struct {
 // Request is the structure of JSON-RPC request
 type Request struct {
 	Base
-	Params interface{}
+	Params        interface{}
+	Trace         string `json:",omitempty"`
+	Timeout       int64  `json:",omitempty"`
+	ProgressToken string `json:",omitempty"`
+	span          *Span
+	ctx           context.Context
+	connID        string
+	conn          net.Conn
+	progress      *Progress
+	running       chan struct{}
 }
 
 // Response is the structure of JSON-RPC response
 type Response struct {
 	Base
-	Result interface{}
-	Error  *Error
+	Result  interface{}
+	Error   *Error
+	Seq     uint64 `json:",omitempty"`
+	Partial bool   `json:",omitempty"`
 }
 
 // RemoteProcedure represents the function-handler that matches a given request
@@ -43,11 +58,41 @@ type DBRemoteProcedure func(db *sql.DB) RemoteProcedure
 
 // Server represents the arca-jsonrpc server
 type Server struct {
-	Address         string
-	plugBlocker     *sync.Mutex
-	writeBlocker    *sync.Mutex
-	conns           []net.Conn
-	listen          net.Listener
-	registersSource map[string]map[string]RemoteProcedure
-	registersTarget map[string]map[string]DBRemoteProcedure
+	Address           string
+	Info              OpenRPCInfo
+	NotFound          RemoteProcedure
+	Network           string
+	SocketMode        os.FileMode
+	ListenerOptions   ListenerOptions
+	SpanExporter      SpanExporter
+	RateLimits        *RateLimits
+	MaxConnections    int
+	IdleTimeout       time.Duration
+	KeepAlive         time.Duration
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+	WriteTimeout      time.Duration
+	MaxRequestSize    int
+	RequestTimeout    time.Duration
+	MethodTimeouts    map[string]time.Duration
+	StreamWindow      int
+	ProgressInterval  time.Duration
+	Framer            Framer
+	Codecs            []Codec
+	CompressionLevel  int
+	ReplaySize        int
+	ReplayAge         time.Duration
+	NotificationStore NotificationStore
+	OnStoreError      func(notification *Notification, err error)
+	plugBlocker       *sync.Mutex
+	conns             []net.Conn
+	sessions          map[net.Conn]*session
+	lastConnID        uint64
+	listens           []net.Listener
+	registerBlocker   *sync.Mutex
+	registers         atomic.Value
+	metrics           *metrics
+	replays           *replay
+	done              chan struct{}
+	closeOnce         sync.Once
 }
@@ -0,0 +1,145 @@
+package jsonrpc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxQueuedRequests is the count of requests of a conn that wait while
+// another of its requests is being handled
+const maxQueuedRequests = 64
+
+var (
+	errCallTimeout = errors.New("Call timed out")
+	errCallClosed  = errors.New("Connection closed before the response")
+)
+
+// Error makes the JSON-RPC error usable as a Go error
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Call sends a request to the client of the connection with the given ID,
+// as returned by Request.ConnID, and waits for its response up to timeout,
+// or forever if timeout is zero. The client answers with a Response that
+// carries the same ID. If the response has an Error, it is returned as err
+// together with the response
+func (s *Server) Call(
+	connID string, method string, context interface{},
+	params interface{}, timeout time.Duration) (*Response, error) {
+	session := s.sessionByID(connID)
+	if session == nil {
+		return nil, errConnIDNotFound
+	}
+	conn := session.conn
+
+	request := &Request{}
+	request.ID = fmt.Sprintf("rpc.call.%d", atomic.AddUint64(&session.callID, 1))
+	request.Method = method
+	request.Context = context
+	request.Params = params
+	msg, err := s.marshal(conn, request)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := session.await(request.ID)
+	defer session.forget(request.ID)
+	if err := s.write(conn, msg); err != nil {
+		return nil, err
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case response := <-pending:
+		if response.Error != nil {
+			return response, response.Error
+		}
+		return response, nil
+	case <-expired:
+		return nil, errCallTimeout
+	case <-session.stopped:
+		return nil, errCallClosed
+	case <-session.closed:
+		return nil, errCallClosed
+	case <-s.done:
+		return nil, errCallClosed
+	}
+}
+
+// await registers a pending call with the given ID
+func (session *session) await(id string) chan *Response {
+	pending := make(chan *Response, 1)
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	session.calls[id] = pending
+	return pending
+}
+
+// forget drops the pending call with the given ID
+func (session *session) forget(id string) {
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	delete(session.calls, id)
+}
+
+// processResponse hands the message to the Call that waits for its ID. It
+// returns false if no Call waits for it or if the message is not shaped as
+// a response, so the message is a request
+func (s *Server) processResponse(
+	raw []byte, w wire, request *Request, session *session) bool {
+	session.blocker.Lock()
+	_, ok := session.calls[request.ID]
+	session.blocker.Unlock()
+	if !ok || !isResponse(raw, w) {
+		return false
+	}
+	session.blocker.Lock()
+	pending, ok := session.calls[request.ID]
+	delete(session.calls, request.ID)
+	session.blocker.Unlock()
+	if !ok {
+		return false
+	}
+
+	response := &Response{}
+	if err := w.codec.Unmarshal(raw, response); err != nil {
+		response = &Response{
+			Base: request.Base,
+			Error: &Error{
+				Message: "Parse error",
+				Code:    -32700,
+				Data:    fmt.Sprint(err),
+			},
+		}
+	}
+	pending <- response
+	return true
+}
+
+// isResponse tells if the message carries a Result or an Error and no
+// Params, so it answers a request instead of being one
+func isResponse(raw []byte, w wire) bool {
+	var fields map[string]interface{}
+	if err := w.codec.Unmarshal(raw, &fields); err != nil {
+		return false
+	}
+	answers := false
+	for name := range fields {
+		switch {
+		case strings.EqualFold(name, "Params"):
+			return false
+		case strings.EqualFold(name, "Result"), strings.EqualFold(name, "Error"):
+			answers = true
+		}
+	}
+	return answers
+}
@@ -0,0 +1,189 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func Test_Call_Client_Responds__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	type answer struct {
+		response *Response
+		err      error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		response, err := server.Call(server.session(server.connections()[0]).id,
+			"Confirm", "Global", "Overwrite?", time.Second)
+		answers <- answer{response, err}
+	}()
+
+	expected := `{"ID":"rpc.call.1","Method":"Confirm","Context":"Global","Params":"Overwrite?"}`
+	actual := receiveString(&conn)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	response := Response{}
+	response.ID = "rpc.call.1"
+	response.Method = "Confirm"
+	response.Result = true
+	msg, _ := json.Marshal(&response)
+	send(&conn, msg)
+
+	received := <-answers
+	if received.err != nil || received.response.Result != true {
+		t.Error("Unexpected answer", received.response, received.err)
+	}
+	server.Close()
+}
+
+func Test_Call_Timeout__Error(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = server.Call(server.session(server.connections()[0]).id,
+		"Confirm", "Global", nil, 100*time.Millisecond)
+	if err != errCallTimeout {
+		t.Error("Expected timeout, got", err)
+	}
+
+	response := Response{}
+	response.ID = "rpc.call.1"
+	response.Method = "Confirm"
+	response.Context = "Global"
+	msg, _ := json.Marshal(&response)
+	send(&conn, msg)
+
+	expected := `{"ID":"rpc.call.1","Method":"Confirm","Context":"Global","Params":null}`
+	actual := receiveString(&conn)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	expected = `{"ID":"rpc.call.1","Method":"Confirm","Context":"Global","Result":null,"Error":{"Code":-32601,"Message":"Method not found","Data":{"ID":"rpc.call.1","Method":"Confirm"}}}`
+	actual = receiveString(&conn)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
+
+func Test_Call_From_Handler__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	server.RegisterSource("Overwrite", "Global",
+		func(request *Request) (result interface{}, err error) {
+			response, err := server.Call(request.ConnID(),
+				"Confirm", "Global", "Overwrite?", time.Second)
+			if err != nil {
+				return
+			}
+			result = response.Result
+			return
+		})
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Overwrite"
+	request.Context = "Global"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Scan()
+	expected := `{"ID":"rpc.call.1","Method":"Confirm","Context":"Global","Params":"Overwrite?"}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+
+	response := Response{}
+	response.ID = "rpc.call.1"
+	response.Method = "Confirm"
+	response.Result = "Yes"
+	msg, _ = json.Marshal(&response)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected = `{"ID":"ID","Method":"Overwrite","Context":"Global","Result":"Yes","Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), server)
+}
+
+func Test_Call_Request_With_Pending_ID_Is_Not_Swallowed__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	answers := make(chan error, 1)
+	go func() {
+		_, err := server.Call(server.session(server.connections()[0]).id,
+			"Confirm", "Global", nil, time.Second)
+		answers <- err
+	}()
+	scanner := bufio.NewScanner(conn)
+	scanner.Scan()
+
+	request := Request{}
+	request.ID = "rpc.call.1"
+	request.Method = "Confirm"
+	request.Context = "Global"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected := `{"ID":"rpc.call.1","Method":"Confirm","Context":"Global","Result":null,"Error":{"Code":-32601,"Message":"Method not found","Data":{"ID":"rpc.call.1","Method":"Confirm"}}}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+
+	send(&conn, []byte(`{"ID":"rpc.call.1","Result":true}`))
+	if err := <-answers; err != nil {
+		t.Error(err)
+	}
+	server.Close()
+}
+
+func Test_Call_From_Handler_Client_Closes__Error(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	called := make(chan error, 1)
+	server.RegisterSource("Delete", "Projects",
+		func(request *Request) (result interface{}, err error) {
+			_, err = server.Call(
+				request.ConnID(), "Confirm", "Projects", nil, 0)
+			called <- err
+			return
+		})
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Delete"
+	request.Context = "Projects"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+
+	expected := `{"ID":"rpc.call.1","Method":"Confirm","Context":"Projects","Params":null}`
+	assertExpectedVsActualAndClose(t, expected, receiveString(&conn), nil)
+	conn.Close()
+
+	select {
+	case err := <-called:
+		if err != errCallClosed {
+			t.Error("Expected the connection closed, got", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Call still waits after the client closed")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if count := len(server.connections()); count != 0 {
+		t.Error("Expected no connections, got", count)
+	}
+	server.Close()
+}
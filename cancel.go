@@ -0,0 +1,61 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+)
+
+var errRequestCancelled = errors.New("Request cancelled")
+
+// track remembers how to cancel the in-flight request with the given ID
+func (session *session) track(id string, cancel context.CancelFunc) {
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	session.inflight[id] = cancel
+}
+
+// untrack forgets the in-flight request with the given ID
+func (session *session) untrack(id string) {
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	delete(session.inflight, id)
+}
+
+// cancel cancels the in-flight request with the given ID, if any
+func (session *session) cancel(id string) {
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	if cancel, ok := session.inflight[id]; ok {
+		cancel()
+	}
+}
+
+// cancelAll cancels all the in-flight requests of the conn
+func (session *session) cancelAll() {
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	for _, cancel := range session.inflight {
+		cancel()
+	}
+}
+
+// processCancel handles the reserved notification $/cancelRequest, whose
+// Params is the ID of an in-flight request of the same conn, as a string or
+// as an object with the field ID. The handler of that request sees its
+// context cancelled and the request is answered with the error -32800.
+// Nothing is answered to the notification itself. It returns false if the
+// request is not $/cancelRequest
+func (s *Server) processCancel(request *Request, session *session) bool {
+	if request.Method != "$/cancelRequest" {
+		return false
+	}
+	switch params := request.Params.(type) {
+	case string:
+		session.cancel(params)
+	case map[string]interface{}:
+		if id, ok := params["ID"].(string); ok {
+			session.cancel(id)
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func Test_Cancel_InFlight_Request__Error(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	started := make(chan struct{})
+	stopped := make(chan bool, 1)
+	server.RegisterSource("Report", "Projects",
+		func(request *Request) (result interface{}, err error) {
+			close(started)
+			select {
+			case <-request.Ctx().Done():
+				stopped <- true
+			case <-time.After(time.Second):
+				stopped <- false
+			}
+			return
+		})
+	server.RegisterSource("Ping", "Projects",
+		func(request *Request) (result interface{}, err error) {
+			result = "Pong"
+			return
+		})
+	scanner := bufio.NewScanner(conn)
+
+	request := Request{}
+	request.ID = "Report 1"
+	request.Method = "Report"
+	request.Context = "Projects"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+	request.ID = "Ping 1"
+	request.Method = "Ping"
+	msg, _ = json.Marshal(&request)
+	send(&conn, msg)
+	<-started
+
+	cancel := Request{}
+	cancel.Method = "$/cancelRequest"
+	cancel.Params = map[string]interface{}{"ID": "Report 1"}
+	msg, _ = json.Marshal(&cancel)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected := `{"ID":"Report 1","Method":"Report","Context":"Projects","Result":null,"Error":{"Code":-32800,"Message":"Request cancelled","Data":{"ID":"Report 1","Method":"Report"}}}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+	scanner.Scan()
+	expected = `{"ID":"Ping 1","Method":"Ping","Context":"Projects","Result":"Pong","Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), server)
+	if !<-stopped {
+		t.Error("The context of the handler was not cancelled")
+	}
+}
+
+func Test_Cancel_Handler_Ignores_Ctx__Error(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	started := make(chan struct{})
+	server.RegisterSource("Report", "Projects",
+		func(request *Request) (result interface{}, err error) {
+			close(started)
+			time.Sleep(500 * time.Millisecond)
+			result = "Done"
+			return
+		})
+	scanner := bufio.NewScanner(conn)
+
+	request := Request{}
+	request.ID = "Report 1"
+	request.Method = "Report"
+	request.Context = "Projects"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+	<-started
+
+	start := time.Now()
+	cancel := Request{}
+	cancel.Method = "$/cancelRequest"
+	cancel.Params = "Report 1"
+	msg, _ = json.Marshal(&cancel)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected := `{"ID":"Report 1","Method":"Report","Context":"Projects","Result":null,"Error":{"Code":-32800,"Message":"Request cancelled","Data":{"ID":"Report 1","Method":"Report"}}}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), server)
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Error("The cancellation was answered late", elapsed)
+	}
+	conn.Close()
+}
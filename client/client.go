@@ -0,0 +1,638 @@
+// Package client is a Go client of arca-jsonrpc servers that survives
+// network drops. After every reconnection it runs Auth again, repeats the
+// subscriptions and asks the server for the broadcasts it missed.
+//
+//	c := &client.Client{Address: "localhost:12345", Replay: true}
+//	c.OnNotification = func(n *jsonrpc.Response) { ... }
+//	c.Start()
+//	defer c.Close()
+//	response, err := c.Call("Get", "Projects", nil)
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	jsonrpc "github.com/m3co/arca-jsonrpc"
+)
+
+// State is the state of the connection of a Client
+type State int
+
+// States of the connection of a Client
+const (
+	Disconnected State = iota
+	Connecting
+	Connected
+	Closed
+)
+
+func (state State) String() string {
+	switch state {
+	case Disconnected:
+		return "Disconnected"
+	case Connecting:
+		return "Connecting"
+	case Connected:
+		return "Connected"
+	case Closed:
+		return "Closed"
+	}
+	return fmt.Sprintf("State(%d)", int(state))
+}
+
+var (
+	// ErrDisconnected is returned by calls made while there is no connection
+	// or whose connection dropped before the response
+	ErrDisconnected = errors.New("Client is disconnected")
+	// ErrTimeout is returned by calls whose response takes longer than
+	// Timeout
+	ErrTimeout = errors.New("Call timed out")
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+	defaultTimeout    = 30 * time.Second
+)
+
+// subscription is a call repeated after every reconnection
+type subscription struct {
+	method  string
+	context interface{}
+	params  interface{}
+}
+
+// Client is a connection to an arca-jsonrpc server that reconnects with
+// exponential backoff and jitter between MinBackoff and MaxBackoff.
+// Callbacks run in the goroutines of the client, so they must not block
+type Client struct {
+	Network    string
+	Address    string
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	Timeout    time.Duration
+	// Auth runs first on every connection, e.g. to call a login method. If
+	// it fails the connection is dropped and tried again
+	Auth func(c *Client) error
+	// Replay asks rpc.replay for the broadcasts missed while reconnecting
+	Replay bool
+	// OnResync runs when the missed broadcasts are not kept by the server
+	// anymore, so the application must reload its data
+	OnResync func()
+	// OnNotification receives the messages that are not responses. Those
+	// numbered by the server arrive once and in order of Seq
+	OnNotification func(notification *jsonrpc.Response)
+	// OnProgress receives the $/progress notifications of the requests
+	OnProgress func(report jsonrpc.ProgressReport)
+	// OnStateChange receives every change of the state of the connection
+	OnStateChange func(state State)
+	// OnRequest answers the requests that the server sends with Call. It
+	// runs in its own goroutine, so it may call the server back. Without
+	// it the requests are answered with "Method not found"
+	OnRequest jsonrpc.RemoteProcedure
+
+	blocker        sync.Mutex
+	writeBlocker   sync.Mutex
+	deliverBlocker sync.Mutex
+	conn           net.Conn
+	state          State
+	lastID         uint64
+	seq            uint64
+	pending        map[string]*call
+	subscriptions  []subscription
+	held           []*jsonrpc.Response
+	arrived        chan struct{}
+	done           chan struct{}
+	closeOnce      sync.Once
+}
+
+// Start launches the connection loop. It returns at once; the state
+// changes to Connected once the connection is established
+func (c *Client) Start() error {
+	if c.Address == "" {
+		return errors.New("Client needs an Address")
+	}
+	c.blocker.Lock()
+	c.pending = make(map[string]*call)
+	c.arrived = make(chan struct{}, 1)
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	c.blocker.Unlock()
+	go c.run()
+	return nil
+}
+
+// Close drops the connection and stops reconnecting. A client closed
+// before Start does not connect
+func (c *Client) Close() error {
+	c.blocker.Lock()
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	c.blocker.Unlock()
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	c.blocker.Lock()
+	conn := c.conn
+	c.blocker.Unlock()
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// State returns the current state of the connection
+func (c *Client) State() State {
+	c.blocker.Lock()
+	defer c.blocker.Unlock()
+	return c.state
+}
+
+// call is a request that waits for its response. Partial results go to
+// onPartial and each of them restarts the Timeout
+type call struct {
+	response  chan *jsonrpc.Response
+	onPartial func(partial *jsonrpc.Response)
+	active    chan struct{}
+}
+
+// Call sends a request and waits for its response up to Timeout. If the
+// response has an Error, it is returned as err together with the response
+func (c *Client) Call(
+	method string, context interface{},
+	params interface{}) (*jsonrpc.Response, error) {
+	return c.call(method, context, params, nil)
+}
+
+// CallStream calls a streaming method. Every partial result goes to
+// onPartial and is acknowledged after it returns, so a slow onPartial
+// slows the server down. The Timeout counts from the last partial result
+func (c *Client) CallStream(
+	method string, context interface{}, params interface{},
+	onPartial func(partial *jsonrpc.Response)) (*jsonrpc.Response, error) {
+	return c.call(method, context, params, onPartial)
+}
+
+func (c *Client) call(
+	method string, context interface{}, params interface{},
+	onPartial func(partial *jsonrpc.Response)) (*jsonrpc.Response, error) {
+	c.blocker.Lock()
+	conn := c.conn
+	if conn == nil {
+		c.blocker.Unlock()
+		return nil, ErrDisconnected
+	}
+	c.lastID++
+	request := &jsonrpc.Request{}
+	request.ID = fmt.Sprintf("client.%d", c.lastID)
+	request.Method = method
+	request.Context = context
+	request.Params = params
+	pending := &call{
+		response:  make(chan *jsonrpc.Response, 1),
+		onPartial: onPartial,
+		active:    make(chan struct{}, 1),
+	}
+	c.pending[request.ID] = pending
+	c.blocker.Unlock()
+
+	defer func() {
+		c.blocker.Lock()
+		delete(c.pending, request.ID)
+		c.blocker.Unlock()
+	}()
+	if err := c.write(conn, request); err != nil {
+		return nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case response, ok := <-pending.response:
+			if !ok {
+				return nil, ErrDisconnected
+			}
+			if response.Error != nil {
+				return response, response.Error
+			}
+			return response, nil
+		case <-pending.active:
+			timer.Stop()
+			select {
+			case <-timer.C:
+			default:
+			}
+			timer.Reset(timeout)
+		case <-timer.C:
+			return nil, ErrTimeout
+		}
+	}
+}
+
+// Subscribe calls the given method now, if connected, and again after
+// every reconnection, after Auth
+func (c *Client) Subscribe(
+	method string, context interface{},
+	params interface{}) (*jsonrpc.Response, error) {
+	c.blocker.Lock()
+	c.subscriptions = append(c.subscriptions,
+		subscription{method: method, context: context, params: params})
+	c.blocker.Unlock()
+	return c.Call(method, context, params)
+}
+
+// write sends a message through the given conn. Writes are serialized by
+// their own lock so a slow network does not hold the state of the client
+func (c *Client) write(conn net.Conn, message interface{}) error {
+	msg, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	c.writeBlocker.Lock()
+	defer c.writeBlocker.Unlock()
+	return jsonrpc.NewlineFramer{}.WriteFrame(conn, msg)
+}
+
+// setState changes the state and tells OnStateChange
+func (c *Client) setState(state State) {
+	c.blocker.Lock()
+	changed := c.state != state
+	c.state = state
+	c.blocker.Unlock()
+	if changed && c.OnStateChange != nil {
+		c.OnStateChange(state)
+	}
+}
+
+// closed tells if Close was called
+func (c *Client) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the time to wait before the given attempt: it doubles
+// from MinBackoff up to MaxBackoff and is randomly reduced up to a half
+func (c *Client) backoff(attempt int) time.Duration {
+	min, max := c.MinBackoff, c.MaxBackoff
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	delay := min
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// run connects, reads until the connection drops and connects again
+func (c *Client) run() {
+	network := c.Network
+	if network == "" {
+		network = "tcp"
+	}
+	attempt := 0
+	for reconnecting := false; !c.closed(); reconnecting = true {
+		if attempt > 0 {
+			select {
+			case <-c.done:
+				c.setState(Closed)
+				return
+			case <-time.After(c.backoff(attempt - 1)):
+			}
+		}
+		c.setState(Connecting)
+		conn, err := net.DialTimeout(network, c.Address, c.backoff(attempt)+time.Second)
+		if err != nil {
+			attempt++
+			c.setState(Disconnected)
+			continue
+		}
+
+		// The numbered notifications are held from the first one read, so
+		// none that arrives during Auth moves seq past the missed ones
+		replaying := c.Replay && reconnecting
+		c.blocker.Lock()
+		c.conn = conn
+		seq := c.seq
+		if replaying {
+			c.held = make([]*jsonrpc.Response, 0)
+		}
+		c.blocker.Unlock()
+		stopped := make(chan struct{})
+		go func() {
+			c.read(conn)
+			close(stopped)
+		}()
+
+		if err := c.establish(replaying, seq); err != nil {
+			conn.Close()
+		} else {
+			attempt = 0
+			c.setState(Connected)
+		}
+		select {
+		case <-stopped:
+		case <-c.done:
+			conn.Close()
+			<-stopped
+		}
+		c.drop()
+		attempt++
+		c.setState(Disconnected)
+	}
+	c.setState(Closed)
+}
+
+// establish runs Auth, repeats the subscriptions and, if replaying, asks
+// for the broadcasts missed after seq. The notifications held meanwhile are
+// delivered once it succeeds and dropped otherwise, as the next connection
+// replays them again
+func (c *Client) establish(replaying bool, seq uint64) (err error) {
+	if replaying {
+		defer func() {
+			if err != nil {
+				c.blocker.Lock()
+				c.held = nil
+				c.blocker.Unlock()
+				return
+			}
+			c.flush()
+		}()
+	}
+	if c.Auth != nil {
+		if err := c.Auth(c); err != nil {
+			return err
+		}
+	}
+	c.blocker.Lock()
+	subscriptions := make([]subscription, len(c.subscriptions))
+	copy(subscriptions, c.subscriptions)
+	c.blocker.Unlock()
+	for _, sub := range subscriptions {
+		if _, err := c.Call(sub.method, sub.context, sub.params); err != nil {
+			return err
+		}
+	}
+	if !replaying {
+		return nil
+	}
+
+	response, err := c.Call("rpc.replay", nil, seq)
+	if rpcErr, ok := err.(*jsonrpc.Error); ok && rpcErr.Code == -32001 {
+		if data, ok := rpcErr.Data.(map[string]interface{}); ok {
+			if current, ok := data["Seq"].(float64); ok {
+				c.blocker.Lock()
+				c.seq = uint64(current)
+				c.blocker.Unlock()
+			}
+		}
+		if c.OnResync != nil {
+			c.OnResync()
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if result, ok := response.Result.(map[string]interface{}); ok {
+		if current, ok := result["Seq"].(float64); ok {
+			c.awaitReplay(seq, uint64(current))
+		}
+	}
+	return nil
+}
+
+// awaitReplay waits until the held notifications cover every sequence
+// after seq up to current, or until Timeout
+func (c *Client) awaitReplay(seq, current uint64) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		covered := make(map[uint64]bool)
+		c.blocker.Lock()
+		for _, held := range c.held {
+			if held.Seq > seq && held.Seq <= current {
+				covered[held.Seq] = true
+			}
+		}
+		c.blocker.Unlock()
+		if uint64(len(covered)) >= current-seq {
+			return
+		}
+		select {
+		case <-c.arrived:
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+// flush delivers the notifications held while replaying in order of Seq
+// and goes back to delivering them as they arrive
+func (c *Client) flush() {
+	c.deliverBlocker.Lock()
+	defer c.deliverBlocker.Unlock()
+	c.blocker.Lock()
+	held := c.held
+	c.held = nil
+	c.blocker.Unlock()
+	sort.Slice(held, func(i, j int) bool {
+		return held[i].Seq < held[j].Seq
+	})
+	for _, notification := range held {
+		c.deliver(notification)
+	}
+}
+
+// deliver hands the notification to OnNotification unless its Seq was
+// already delivered. The caller holds deliverBlocker
+func (c *Client) deliver(notification *jsonrpc.Response) {
+	if notification.Seq > 0 {
+		c.blocker.Lock()
+		duplicated := notification.Seq <= c.seq
+		if !duplicated {
+			c.seq = notification.Seq
+		}
+		c.blocker.Unlock()
+		if duplicated {
+			return
+		}
+	}
+	if c.OnNotification != nil {
+		c.OnNotification(notification)
+	}
+}
+
+// drop forgets the conn and fails the pending calls
+func (c *Client) drop() {
+	c.blocker.Lock()
+	defer c.blocker.Unlock()
+	c.conn = nil
+	for id, pending := range c.pending {
+		close(pending.response)
+		delete(c.pending, id)
+	}
+}
+
+// read dispatches the messages of conn until it fails
+func (c *Client) read(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		raw, err := jsonrpc.NewlineFramer{}.ReadFrame(reader, 64<<20)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		if len(raw) == 0 {
+			continue
+		}
+		message := struct {
+			jsonrpc.Response
+			Params json.RawMessage
+		}{}
+		if err := json.Unmarshal(raw, &message); err != nil {
+			continue
+		}
+		if message.ID != "" && message.Params != nil {
+			request := &jsonrpc.Request{}
+			if err := json.Unmarshal(raw, request); err != nil {
+				continue
+			}
+			go c.answer(conn, request)
+			continue
+		}
+		c.dispatch(conn, &message.Response)
+	}
+}
+
+// answer replies a request sent by the server with the result of OnRequest
+func (c *Client) answer(conn net.Conn, request *jsonrpc.Request) {
+	response := &jsonrpc.Response{Base: request.Base}
+	if c.OnRequest == nil {
+		response.Error = &jsonrpc.Error{
+			Message: "Method not found",
+			Code:    -32601,
+			Data: map[string]string{
+				"Method": request.Method,
+				"ID":     request.ID,
+			},
+		}
+	} else if result, err := c.OnRequest(request); err != nil {
+		rpcErr, ok := err.(*jsonrpc.Error)
+		if !ok {
+			rpcErr = &jsonrpc.Error{
+				Message: "Internal error",
+				Code:    -32603,
+				Data: map[string]string{
+					"Error":  err.Error(),
+					"Method": request.Method,
+					"ID":     request.ID,
+				},
+			}
+		}
+		response.Error = rpcErr
+	} else {
+		response.Result = result
+	}
+	if err := c.write(conn, response); err != nil {
+		//log.Println("answer:write", err)
+	}
+}
+
+// dispatch hands the response to the call that waits for it, answers the
+// heartbeat of the server, reports the progress or delivers the
+// notification
+func (c *Client) dispatch(conn net.Conn, response *jsonrpc.Response) {
+	c.blocker.Lock()
+	pending, ok := c.pending[response.ID]
+	if ok && !response.Partial {
+		delete(c.pending, response.ID)
+	}
+	c.blocker.Unlock()
+	if ok && response.Partial {
+		if pending.onPartial != nil {
+			pending.onPartial(response)
+		}
+		select {
+		case pending.active <- struct{}{}:
+		default:
+		}
+		ack := &jsonrpc.Request{}
+		ack.Method = "$/streamAck"
+		ack.Params = map[string]interface{}{"ID": response.ID, "Count": 1}
+		if err := c.write(conn, ack); err != nil {
+			//log.Println("dispatch:write", err)
+		}
+		return
+	}
+	if ok {
+		pending.response <- response
+		return
+	}
+
+	if response.Method == "$/progress" && response.ID == "" {
+		if c.OnProgress != nil {
+			report := jsonrpc.ProgressReport{}
+			raw, err := json.Marshal(response.Result)
+			if err == nil && json.Unmarshal(raw, &report) == nil {
+				c.OnProgress(report)
+			}
+		}
+		return
+	}
+
+	if response.Method == "rpc.ping" && response.ID == "" {
+		pong := &jsonrpc.Request{}
+		pong.Method = "rpc.pong"
+		if err := c.write(conn, pong); err != nil {
+			//log.Println("dispatch:write", err)
+		}
+		return
+	}
+
+	c.deliverBlocker.Lock()
+	defer c.deliverBlocker.Unlock()
+	c.blocker.Lock()
+	holding := c.held != nil && response.Seq > 0
+	if holding {
+		c.held = append(c.held, response)
+	}
+	c.blocker.Unlock()
+	if holding {
+		select {
+		case c.arrived <- struct{}{}:
+		default:
+		}
+		return
+	}
+	c.deliver(response)
+}
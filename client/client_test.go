@@ -0,0 +1,290 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	jsonrpc "github.com/m3co/arca-jsonrpc"
+)
+
+// droppingListener remembers the accepted conns so the test can drop them
+type droppingListener struct {
+	net.Listener
+	blocker sync.Mutex
+	conns   []net.Conn
+}
+
+func (l *droppingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.blocker.Lock()
+		l.conns = append(l.conns, conn)
+		l.blocker.Unlock()
+	}
+	return conn, err
+}
+
+func (l *droppingListener) drop() {
+	l.blocker.Lock()
+	defer l.blocker.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+	l.conns = nil
+}
+
+func Test_Client_Reconnects_And_Replays__OK(t *testing.T) {
+	listen, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dropping := &droppingListener{Listener: listen}
+	server := &jsonrpc.Server{ReplaySize: 10}
+	if err := server.Serve(dropping); err != nil {
+		t.Error(err)
+		return
+	}
+	defer server.Close()
+
+	var blocker sync.Mutex
+	logins, subscriptions := 0, 0
+	server.RegisterSource("Login", "Session",
+		func(request *jsonrpc.Request) (result interface{}, err error) {
+			blocker.Lock()
+			logins++
+			blocker.Unlock()
+			result = "OK"
+			return
+		})
+	server.RegisterSource("Subscribe", "News",
+		func(request *jsonrpc.Request) (result interface{}, err error) {
+			blocker.Lock()
+			subscriptions++
+			blocker.Unlock()
+			result = "OK"
+			return
+		})
+
+	states := make(chan State, 10)
+	notifications := make(chan interface{}, 10)
+	c := &Client{
+		Address:    listen.Addr().String(),
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+		Timeout:    time.Second,
+		Replay:     true,
+		Auth: func(c *Client) error {
+			_, err := c.Call("Login", "Session", nil)
+			blocker.Lock()
+			reconnected := logins == 2
+			blocker.Unlock()
+			if reconnected {
+				// A live broadcast arrives before the replay is asked
+				server.Notify("Updated", "News", "3")
+				time.Sleep(100 * time.Millisecond)
+			}
+			return err
+		},
+		OnStateChange: func(state State) {
+			states <- state
+		},
+		OnNotification: func(notification *jsonrpc.Response) {
+			notifications <- notification.Result
+		},
+	}
+	if err := c.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	defer c.Close()
+	awaitState := func(expected State) {
+		for {
+			select {
+			case state := <-states:
+				if state == expected {
+					return
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Expected state", expected)
+			}
+		}
+	}
+	awaitState(Connected)
+	if _, err := c.Subscribe("Subscribe", "News", nil); err != nil {
+		t.Error(err)
+	}
+
+	server.Notify("Updated", "News", "1")
+	if result := <-notifications; result != "1" {
+		t.Error("Expected 1, got", result)
+	}
+
+	dropping.drop()
+	awaitState(Disconnected)
+	server.Notify("Updated", "News", "2")
+	awaitState(Connected)
+
+	for _, expected := range []string{"2", "3"} {
+		select {
+		case result := <-notifications:
+			if result != expected {
+				t.Error("Expected", expected, "got", result)
+			}
+		case <-time.After(2 * time.Second):
+			t.Error("Missed broadcast was not replayed")
+		}
+	}
+	blocker.Lock()
+	if logins != 2 || subscriptions != 2 {
+		t.Error("Expected 2 logins and 2 subscriptions, got",
+			logins, subscriptions)
+	}
+	blocker.Unlock()
+}
+
+func Test_Client_Call_While_Disconnected__Error(t *testing.T) {
+	c := &Client{Address: "localhost:1", MinBackoff: time.Hour}
+	if err := c.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	defer c.Close()
+	if _, err := c.Call("Get", "Projects", nil); err != ErrDisconnected {
+		t.Error("Expected ErrDisconnected, got", err)
+	}
+}
+
+func Test_Client_CallStream__OK(t *testing.T) {
+	listen, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	server := &jsonrpc.Server{StreamWindow: 1}
+	if err := server.Serve(listen); err != nil {
+		t.Error(err)
+		return
+	}
+	defer server.Close()
+	server.RegisterStream("Export", "Projects",
+		func(request *jsonrpc.Request,
+			stream *jsonrpc.Stream) (result interface{}, err error) {
+			for i := 0; i < 5; i++ {
+				if err = stream.Send(i); err != nil {
+					return
+				}
+			}
+			return
+		})
+
+	connected := make(chan struct{})
+	c := &Client{
+		Address: listen.Addr().String(),
+		Timeout: time.Second,
+		OnStateChange: func(state State) {
+			if state == Connected {
+				close(connected)
+			}
+		},
+	}
+	if err := c.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	defer c.Close()
+	<-connected
+
+	partials := make([]interface{}, 0)
+	response, err := c.CallStream("Export", "Projects", nil,
+		func(partial *jsonrpc.Response) {
+			partials = append(partials, partial.Result)
+		})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(partials) != 5 || response.Result != float64(5) {
+		t.Error("Unexpected stream", partials, response.Result)
+	}
+}
+
+func Test_Client_Close_Before_Start__OK(t *testing.T) {
+	c := &Client{Address: "localhost:1"}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+	states := make(chan State, 10)
+	c.OnStateChange = func(state State) {
+		states <- state
+	}
+	if err := c.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	select {
+	case state := <-states:
+		if state != Closed {
+			t.Error("Expected Closed, got", state)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the client to stay closed")
+	}
+}
+
+func Test_Client_Answers_Server_Call__OK(t *testing.T) {
+	listen, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	server := &jsonrpc.Server{}
+	if err := server.Serve(listen); err != nil {
+		t.Error(err)
+		return
+	}
+	defer server.Close()
+	server.RegisterSource("Overwrite", "Projects",
+		func(request *jsonrpc.Request) (result interface{}, err error) {
+			response, err := server.Call(request.ConnID(),
+				"Confirm", "Projects", request.Params, time.Second)
+			if err != nil {
+				return
+			}
+			result = response.Result
+			return
+		})
+
+	connected := make(chan struct{})
+	c := &Client{
+		Address: listen.Addr().String(),
+		Timeout: time.Second,
+		OnStateChange: func(state State) {
+			if state == Connected {
+				close(connected)
+			}
+		},
+		OnRequest: func(
+			request *jsonrpc.Request) (result interface{}, err error) {
+			result = request.Method + " " + request.Params.(string)
+			return
+		},
+	}
+	if err := c.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	defer c.Close()
+	<-connected
+
+	response, err := c.Call("Overwrite", "Projects", "row 1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if response.Result != "Confirm row 1" {
+		t.Error("Unexpected result", response.Result)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+
+	jsonrpc "github.com/m3co/arca-jsonrpc"
+)
+
+// golang writes the Go client stub of the document. The stub does not
+// depend on any transport: it wraps a Caller that sends the request
+// envelope and decodes the Result of the response into result
+func golang(
+	document *jsonrpc.OpenRPCDocument, list []stub, pkg string) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by arca-jsonrpc-gen from %s %s. DO NOT EDIT.\n\n",
+		document.Info.Title, document.Info.Version)
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+
+	b.WriteString("// Caller sends a request and decodes the Result of its response\n")
+	b.WriteString("type Caller interface {\n")
+	b.WriteString("\tCall(method string, context string, params interface{}, result interface{}) error\n")
+	b.WriteString("}\n\n")
+	b.WriteString("// Client calls the Source methods of the server\n")
+	b.WriteString("type Client struct {\n\tCaller Caller\n}\n\n")
+
+	for _, st := range list {
+		if !st.broadcast {
+			fmt.Fprintf(&b, "// %sParams are the params of %s\n",
+				st.name, st.context+"."+st.method)
+			fmt.Fprintf(&b, "type %sParams %s\n\n", st.name, goType(st.params))
+		}
+		fmt.Fprintf(&b, "// %sResult is the result of %s\n",
+			st.name, st.context+"."+st.method)
+		fmt.Fprintf(&b, "type %sResult %s\n\n", st.name, goType(st.result))
+	}
+
+	for _, st := range list {
+		if st.broadcast {
+			continue
+		}
+		summary := st.summary
+		if summary == "" {
+			summary = "calls " + st.context + "." + st.method
+		}
+		fmt.Fprintf(&b, "// %s %s\n", st.name,
+			strings.Replace(summary, "\n", " ", -1))
+		fmt.Fprintf(&b, "func (c *Client) %s(params %sParams) (result %sResult, err error) {\n",
+			st.name, st.name, st.name)
+		fmt.Fprintf(&b, "\terr = c.Caller.Call(%s, %s, params, &result)\n",
+			quote(st.method), quote(st.context))
+		b.WriteString("\treturn\n}\n\n")
+	}
+
+	b.WriteString("// Broadcasts maps Context.Method to the type of its broadcasted result\n")
+	b.WriteString("var Broadcasts = map[string]func() interface{}{\n")
+	for _, st := range list {
+		if st.broadcast {
+			fmt.Fprintf(&b, "\t%s: func() interface{} { return new(%sResult) },\n",
+				quote(st.context+"."+st.method), st.name)
+		}
+	}
+	b.WriteString("}\n")
+
+	return format.Source([]byte(b.String()))
+}
+
+// goType returns the Go type of the schema
+func goType(s *schema) string {
+	name, nullable := s.typeName()
+	var t string
+	switch name {
+	case "string":
+		t = "string"
+	case "integer":
+		t = "int64"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		item := "interface{}"
+		if s.Items != nil {
+			item = goType(s.Items)
+		}
+		return "[]" + item
+	case "object":
+		if len(s.Properties) == 0 {
+			return "map[string]interface{}"
+		}
+		var b strings.Builder
+		b.WriteString("struct {\n")
+		fields := make(map[string]bool, len(s.Properties))
+		for _, property := range s.propertyNames() {
+			field := goType(s.Properties[property])
+			tag := property
+			if !s.isRequired(property) {
+				tag += ",omitempty"
+			}
+			// The tag keeps the name of the property, so a field whose
+			// identifier is taken just gets a suffix
+			name := identifier(property)
+			for i := 2; fields[name]; i++ {
+				name = fmt.Sprintf("%s%d", identifier(property), i)
+			}
+			fields[name] = true
+			fmt.Fprintf(&b, "%s %s `json:%s`\n", name, field, quote(tag))
+		}
+		b.WriteString("}")
+		return b.String()
+	default:
+		return "interface{}"
+	}
+	if nullable {
+		t = "*" + t
+	}
+	return t
+}
@@ -0,0 +1,146 @@
+// Command arca-jsonrpc-gen writes the OpenRPC document of an arca-jsonrpc
+// server together with typed TypeScript and Go client stubs for every
+// Source method and broadcast message.
+//
+// The document is read from a running server through rpc.discover:
+//
+//	arca-jsonrpc-gen -addr localhost:12345 -out ./api
+//
+// or from a file, e.g. one written by a Go program with server.OpenRPC():
+//
+//	arca-jsonrpc-gen -openrpc openrpc.json -out ./api -package api
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	jsonrpc "github.com/m3co/arca-jsonrpc"
+)
+
+func main() {
+	addr := flag.String("addr", "", "address of a running server")
+	network := flag.String("network", "tcp", "network of the running server")
+	input := flag.String("openrpc", "", "OpenRPC document to read")
+	out := flag.String("out", ".", "directory where the files are written")
+	pkg := flag.String("package", "api", "package of the Go client stub")
+	flag.Parse()
+
+	if err := run(*network, *addr, *input, *out, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, "arca-jsonrpc-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(network, addr, input, out, pkg string) error {
+	var document *jsonrpc.OpenRPCDocument
+	var err error
+	switch {
+	case addr != "":
+		document, err = discover(network, addr)
+	case input != "":
+		document, err = readDocument(input)
+	default:
+		err = errors.New("either -addr or -openrpc is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	list, err := stubs(document)
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{
+		"openrpc.json": append(raw, '\n'),
+		"client.ts":    []byte(typescript(document, list)),
+	}
+	if files["client.go"], err = golang(document, list, pkg); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	for name, content := range files {
+		if err := os.WriteFile(
+			filepath.Join(out, name), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discover asks a running server for its OpenRPC document
+func discover(network, addr string) (*jsonrpc.OpenRPCDocument, error) {
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	request := &jsonrpc.Request{}
+	request.ID = "rpc.discover"
+	request.Method = "rpc.discover"
+	msg, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	framer := jsonrpc.NewlineFramer{}
+	if err := framer.WriteFrame(conn, msg); err != nil {
+		return nil, err
+	}
+
+	// Broadcasts and pings may come before the answer
+	reader := bufio.NewReader(conn)
+	var raw []byte
+	for {
+		if raw, err = framer.ReadFrame(reader, 64<<20); err != nil {
+			return nil, err
+		}
+		var base struct{ ID string }
+		if json.Unmarshal(raw, &base) == nil && base.ID == request.ID {
+			break
+		}
+	}
+
+	var response struct {
+		Result *jsonrpc.OpenRPCDocument
+		Error  *jsonrpc.Error
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("rpc.discover: %s", response.Error.Message)
+	}
+	if response.Result == nil {
+		return nil, errors.New("rpc.discover: empty result")
+	}
+	return response.Result, nil
+}
+
+// readDocument reads an OpenRPC document from a file
+func readDocument(path string) (*jsonrpc.OpenRPCDocument, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	document := &jsonrpc.OpenRPCDocument{}
+	if err := json.Unmarshal(raw, document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jsonrpc "github.com/m3co/arca-jsonrpc"
+)
+
+func Test_Generate_From_Running_Server__OK(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	server := &jsonrpc.Server{}
+	server.Serve(listen)
+	defer server.Close()
+
+	pung := func(request *jsonrpc.Request) (result interface{}, err error) {
+		return
+	}
+	server.RegisterSource("GetRows", "Projects", pung)
+	server.DescribeSource("GetRows", "Projects", jsonrpc.MethodDescription{
+		Summary: "returns the rows of a project",
+		Params: map[string]interface{}{
+			"type":       "object",
+			"required":   []interface{}{"ID"},
+			"properties": map[string]interface{}{"ID": map[string]interface{}{"type": "integer"}},
+		},
+		Result: map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": []interface{}{"string", "null"}},
+		},
+	})
+
+	out := t.TempDir()
+	if err := run("tcp", listen.Addr().String(), "", out, "api"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expectations := map[string][]string{
+		"openrpc.json": {`"name": "Projects.GetRows"`},
+		"client.ts": {
+			"export type ProjectsGetRowsParams = {\n  \"ID\": number;\n};",
+			"export type ProjectsGetRowsResult = Array<string | null>;",
+			"/** returns the rows of a project */",
+			`return this.caller.call("GetRows", "Projects", params) as Promise<ProjectsGetRowsResult>;`,
+		},
+		"client.go": {
+			"package api",
+			"type ProjectsGetRowsParams struct {\n\tID int64 `json:\"ID\"`\n}",
+			"type ProjectsGetRowsResult []*string",
+			"func (c *Client) ProjectsGetRows(params ProjectsGetRowsParams) (result ProjectsGetRowsResult, err error) {",
+		},
+	}
+	for name, expected := range expectations {
+		raw, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		for _, snippet := range expected {
+			if !strings.Contains(string(raw), snippet) {
+				t.Errorf("%s\nexpect %s\nactual %s", name, snippet, raw)
+			}
+		}
+	}
+}
+
+func Test_Generate_Colliding_Identifiers__Fail(t *testing.T) {
+	document := &jsonrpc.OpenRPCDocument{
+		Methods: []jsonrpc.OpenRPCMethod{
+			{Name: "a_b.c", Context: "a_b", Method: "c"},
+			{Name: "a.b_c", Context: "a", Method: "b_c"},
+		},
+	}
+	if _, err := stubs(document); err == nil {
+		t.Error("Expecting a collision between a_b.c and a.b_c")
+	}
+}
+
+func Test_Generate_Skips_Wildcards__OK(t *testing.T) {
+	document := &jsonrpc.OpenRPCDocument{
+		Methods: []jsonrpc.OpenRPCMethod{
+			{Name: "*.Ping", Context: "*", Method: "Ping"},
+			{Name: "Projects.Get*", Context: "Projects", Method: "Get*"},
+			{Name: "Projects.GetRows", Context: "Projects", Method: "GetRows"},
+		},
+	}
+	list, err := stubs(document)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(list) != 1 || list[0].name != "ProjectsGetRows" {
+		t.Errorf("expect [ProjectsGetRows], actual %v", list)
+	}
+}
+
+func Test_Generate_Discover_Skips_Other_Messages__OK(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer listen.Close()
+	go func() {
+		conn, err := listen.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		bufio.NewReader(conn).ReadString('\n')
+		conn.Write([]byte(`{"Method":"rpc.ping","Context":null,"Params":null}` + "\n"))
+		conn.Write([]byte(`{"Method":"Update","Context":"Projects","Result":1}` + "\n"))
+		conn.Write([]byte(`{"ID":"rpc.discover","Result":{"openrpc":"1.2.6","methods":[]}}` + "\n"))
+	}()
+
+	document, err := discover("tcp", listen.Addr().String())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if document.OpenRPC != "1.2.6" {
+		t.Errorf("expect 1.2.6, actual %v", document.OpenRPC)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	jsonrpc "github.com/m3co/arca-jsonrpc"
+)
+
+// schema is the subset of JSON schema that the stubs understand. Anything
+// else becomes an untyped value
+type schema struct {
+	Type       interface{}
+	Properties map[string]*schema
+	Required   []string
+	Items      *schema
+	Enum       []interface{}
+}
+
+// parseSchema converts the generic schema of a content descriptor
+func parseSchema(generic interface{}) *schema {
+	object, ok := generic.(map[string]interface{})
+	if !ok {
+		return &schema{}
+	}
+	parsed := &schema{Type: object["type"]}
+	if properties, ok := object["properties"].(map[string]interface{}); ok {
+		parsed.Properties = make(map[string]*schema, len(properties))
+		for name, property := range properties {
+			parsed.Properties[name] = parseSchema(property)
+		}
+	}
+	if required, ok := object["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				parsed.Required = append(parsed.Required, name)
+			}
+		}
+	}
+	if items, ok := object["items"]; ok {
+		parsed.Items = parseSchema(items)
+	}
+	if enum, ok := object["enum"].([]interface{}); ok {
+		parsed.Enum = enum
+	}
+	return parsed
+}
+
+// typeName returns the single type of the schema, ignoring null in a list
+// of types. Several non-null types give an empty name
+func (s *schema) typeName() (name string, nullable bool) {
+	switch t := s.Type.(type) {
+	case string:
+		return t, false
+	case []interface{}:
+		for _, item := range t {
+			item, _ := item.(string)
+			if item == "null" {
+				nullable = true
+				continue
+			}
+			if name != "" {
+				return "", nullable
+			}
+			name = item
+		}
+	}
+	return
+}
+
+// isRequired tells if the property is listed as required
+func (s *schema) isRequired(property string) bool {
+	for _, name := range s.Required {
+		if name == property {
+			return true
+		}
+	}
+	return false
+}
+
+// propertyNames returns the names of the properties sorted
+func (s *schema) propertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// identifier turns the given words into an exported identifier
+func identifier(words ...string) string {
+	var b strings.Builder
+	for _, word := range words {
+		upper := true
+		for _, r := range word {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				upper = true
+				continue
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
+
+// stub is a method of the document ready to be written as code
+type stub struct {
+	name      string
+	context   string
+	method    string
+	summary   string
+	params    *schema
+	result    *schema
+	broadcast bool
+}
+
+// stubs lists the methods of the document with unique identifiers. The
+// registrations with wildcards have no fixed name to call, so they are
+// skipped. Two methods that map to the same identifier are an error
+func stubs(document *jsonrpc.OpenRPCDocument) ([]stub, error) {
+	list := make([]stub, 0, len(document.Methods))
+	owners := make(map[string]string, len(document.Methods))
+	for _, method := range document.Methods {
+		context, name := method.Context, method.Method
+		if context == "" && name == "" {
+			name = method.Name
+		}
+		if strings.ContainsAny(context+name, "*?[") {
+			continue
+		}
+		st := stub{
+			name:      identifier(context, name),
+			context:   context,
+			method:    name,
+			summary:   method.Summary,
+			params:    &schema{},
+			result:    &schema{},
+			broadcast: method.Broadcast,
+		}
+		if st.broadcast {
+			st.name += "Broadcast"
+		}
+		if owner, ok := owners[st.name]; ok {
+			return nil, fmt.Errorf("%s and %s both map to the identifier %s",
+				owner, method.Name, st.name)
+		}
+		owners[st.name] = method.Name
+		if len(method.Params) > 0 {
+			st.params = parseSchema(method.Params[0].Schema)
+		}
+		if method.Result != nil {
+			st.result = parseSchema(method.Result.Schema)
+		}
+		list = append(list, st)
+	}
+	return list, nil
+}
+
+// quote returns s as a double-quoted literal valid in Go and TypeScript
+func quote(s string) string {
+	raw, _ := json.Marshal(s)
+	return string(raw)
+}
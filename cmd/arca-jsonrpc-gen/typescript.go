@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	jsonrpc "github.com/m3co/arca-jsonrpc"
+)
+
+// typescript writes the TypeScript client stub of the document. The stub
+// does not depend on any transport: it wraps a Caller that sends the
+// request envelope and resolves with the Result of the response
+func typescript(document *jsonrpc.OpenRPCDocument, list []stub) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by arca-jsonrpc-gen from %s %s. DO NOT EDIT.\n\n",
+		document.Info.Title, document.Info.Version)
+
+	for _, st := range list {
+		if !st.broadcast {
+			fmt.Fprintf(&b, "export type %sParams = %s;\n",
+				st.name, tsType(st.params, ""))
+		}
+		fmt.Fprintf(&b, "export type %sResult = %s;\n\n",
+			st.name, tsType(st.result, ""))
+	}
+
+	b.WriteString("export interface Caller {\n")
+	b.WriteString("  call(method: string, context: string, params: unknown): Promise<unknown>;\n")
+	b.WriteString("}\n\n")
+
+	b.WriteString("export class Client {\n")
+	b.WriteString("  constructor(private readonly caller: Caller) {}\n")
+	for _, st := range list {
+		if st.broadcast {
+			continue
+		}
+		b.WriteString("\n")
+		if st.summary != "" {
+			fmt.Fprintf(&b, "  /** %s */\n", strings.Replace(st.summary, "*/", "* /", -1))
+		}
+		fmt.Fprintf(&b, "  %s(params: %sParams): Promise<%sResult> {\n",
+			lowerFirst(st.name), st.name, st.name)
+		fmt.Fprintf(&b, "    return this.caller.call(%s, %s, params) as Promise<%sResult>;\n",
+			quote(st.method), quote(st.context), st.name)
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("/** Results broadcasted by the server, keyed by Context.Method */\n")
+	b.WriteString("export interface Broadcasts {\n")
+	for _, st := range list {
+		if st.broadcast {
+			fmt.Fprintf(&b, "  %s: %sResult;\n",
+				quote(st.context+"."+st.method), st.name)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// tsType returns the TypeScript type of the schema
+func tsType(s *schema, indent string) string {
+	if len(s.Enum) > 0 {
+		literals := make([]string, 0, len(s.Enum))
+		for _, value := range s.Enum {
+			if value, ok := value.(string); ok {
+				literals = append(literals, quote(value))
+			} else {
+				literals = append(literals, fmt.Sprint(value))
+			}
+		}
+		return strings.Join(literals, " | ")
+	}
+
+	name, nullable := s.typeName()
+	var t string
+	switch name {
+	case "string":
+		t = "string"
+	case "integer", "number":
+		t = "number"
+	case "boolean":
+		t = "boolean"
+	case "null":
+		t = "null"
+	case "array":
+		item := "unknown"
+		if s.Items != nil {
+			item = tsType(s.Items, indent)
+		}
+		t = "Array<" + item + ">"
+	case "object":
+		if len(s.Properties) == 0 {
+			t = "Record<string, unknown>"
+			break
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, property := range s.propertyNames() {
+			optional := "?"
+			if s.isRequired(property) {
+				optional = ""
+			}
+			fmt.Fprintf(&b, "%s  %s%s: %s;\n", indent, quote(property), optional,
+				tsType(s.Properties[property], indent+"  "))
+		}
+		b.WriteString(indent + "}")
+		t = b.String()
+	default:
+		t = "unknown"
+	}
+	if nullable && t != "unknown" {
+		t += " | null"
+	}
+	return t
+}
+
+// lowerFirst lowers the first letter of an identifier
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -0,0 +1,191 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+)
+
+// Codec encodes and decodes the messages exchanged with a conn
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes the messages as JSON. It is the default
+type JSONCodec struct{}
+
+// Name returns json
+func (JSONCodec) Name() string {
+	return "json"
+}
+
+// Marshal encodes v as JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// codecs returns the codecs a conn may switch to
+func (s *Server) codecs() []Codec {
+	if s.Codecs != nil {
+		return s.Codecs
+	}
+	return []Codec{JSONCodec{}, MsgpackCodec{}}
+}
+
+// wire describes how the messages sent to a conn are framed, encoded and
+// compressed
+type wire struct {
+	framer      Framer
+	codec       Codec
+	compression string
+}
+
+// key identifies the bytes that a wire puts between the framing
+func (w wire) key() string {
+	return w.codec.Name() + "/" + w.compression
+}
+
+// encoding returns the wire that the given conn speaks
+func (s *Server) encoding(conn net.Conn) wire {
+	session := s.session(conn)
+	if session == nil {
+		return wire{framer: s.framer(), codec: JSONCodec{}}
+	}
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	return session.wire
+}
+
+// marshal encodes v with the codec of the given conn and compresses it if
+// the conn asked so
+func (s *Server) marshal(conn net.Conn, v interface{}) ([]byte, error) {
+	w := s.encoding(conn)
+	msg, err := w.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return s.compress(msg, w.compression)
+}
+
+// transcode re-encodes the JSON msg with the codec and compression of the
+// given wire. Numbers are handed to the codec as json.Number so they keep
+// their precision. The results are kept in cache so every wire encodes a
+// broadcast only once
+func (s *Server) transcode(
+	msg []byte, w wire, cache map[string][]byte) ([]byte, error) {
+	if encoded, ok := cache[w.key()]; ok {
+		return encoded, nil
+	}
+	encoded := msg
+	if _, ok := w.codec.(JSONCodec); !ok {
+		var generic interface{}
+		decoder := json.NewDecoder(bytes.NewReader(msg))
+		decoder.UseNumber()
+		if err := decoder.Decode(&generic); err != nil {
+			return nil, err
+		}
+		var err error
+		if encoded, err = w.codec.Marshal(generic); err != nil {
+			return nil, err
+		}
+	}
+	encoded, err := s.compress(encoded, w.compression)
+	if err != nil {
+		return nil, err
+	}
+	cache[w.key()] = encoded
+	return encoded, nil
+}
+
+// processCodec answers the reserved method rpc.codec, whose Params is the
+// name of the codec the client wants to speak from now on. The answer is
+// still encoded with the previous codec. Binary codecs cannot be delimited
+// by newlines, so a conn that switches to them also switches to the
+// ContentLengthFramer. It returns false if the request is not rpc.codec
+func (s *Server) processCodec(request *Request, conn net.Conn) bool {
+	if request.Method != "rpc.codec" {
+		return false
+	}
+	name, _ := request.Params.(string)
+	found := s.codec(name)
+	if found == nil {
+		if err := s.sendError(conn, &request.Base, &Error{
+			Message: "Invalid params",
+			Code:    -32602,
+			Data: map[string]string{
+				"Method": request.Method,
+				"ID":     request.ID,
+				"Error":  "Unknown codec " + name,
+			},
+		}); err != nil {
+			//log.Println("processCodec:sendError", err)
+		}
+		return true
+	}
+
+	if err := s.send(conn, &Response{
+		Base:   request.Base,
+		Result: found.Name(),
+	}); err != nil {
+		//log.Println("processCodec:send", err)
+	}
+	session := s.session(conn)
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	session.wire = session.wire.withCodec(found)
+	return true
+}
+
+// codec returns the codec of the given name or nil if the conns may not
+// switch to it
+func (s *Server) codec(name string) Codec {
+	var found Codec
+	for _, codec := range s.codecs() {
+		if codec.Name() == name {
+			found = codec
+		}
+	}
+	return found
+}
+
+// withCodec returns the wire that encodes with the given codec
+func (w wire) withCodec(codec Codec) wire {
+	w.codec = codec
+	if _, ok := codec.(JSONCodec); !ok {
+		w.framer = binaryFramer(w.framer)
+	}
+	return w
+}
+
+// switchWire returns the wire that the conn reads once the given request
+// is answered. Only valid rpc.codec and rpc.compress change it
+func (s *Server) switchWire(request *Request, w wire) wire {
+	switch request.Method {
+	case "rpc.codec":
+		name, _ := request.Params.(string)
+		if found := s.codec(name); found != nil {
+			return w.withCodec(found)
+		}
+	case "rpc.compress":
+		algorithm, _ := request.Params.(string)
+		if _, err := s.compress(nil, algorithm); err == nil {
+			return w.withCompression(algorithm)
+		}
+	}
+	return w
+}
+
+// binaryFramer returns a framer able to delimit binary messages
+func binaryFramer(framer Framer) Framer {
+	if _, ok := framer.(NewlineFramer); ok {
+		return ContentLengthFramer{}
+	}
+	return framer
+}
@@ -0,0 +1,220 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Codec_Msgpack_Same_Shape_As_JSON__OK(t *testing.T) {
+	response := &Response{
+		Base: Base{
+			ID:      "ID",
+			Method:  "Ping",
+			Context: map[string]interface{}{"Source": "Global"},
+		},
+		Result: []interface{}{1, -200, 3.5, "Pong", true, nil, 70000},
+	}
+
+	msg, err := MsgpackCodec{}.Marshal(response)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var decoded interface{}
+	if err := (MsgpackCodec{}).Unmarshal(msg, &decoded); err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected, _ := json.Marshal(response)
+	actual, _ := json.Marshal(decoded)
+	var expectedGeneric interface{}
+	json.Unmarshal(expected, &expectedGeneric)
+	expected, _ = json.Marshal(expectedGeneric)
+	assertExpectedVsActualAndClose(t, string(expected), string(actual), nil)
+}
+
+func Test_Codec_Negotiate_Msgpack_And_Broadcast__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+	plain, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+	}
+
+	pung :=
+		func(request *Request) (result interface{}, err error) {
+			result = "Pung"
+			return
+		}
+	server.RegisterSource("Pung", "Global", pung)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.codec"
+	request.Params = "msgpack"
+	expected := `{"ID":"ID","Method":"rpc.codec","Context":null,"Result":"msgpack","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	codec := MsgpackCodec{}
+	framer := ContentLengthFramer{}
+	reader := bufio.NewReader(conn)
+
+	request.Method = "Pung"
+	request.Context = "Global"
+	request.Params = nil
+	msg, _ := codec.Marshal(&request)
+	framer.WriteFrame(conn, msg)
+	raw, err := framer.ReadFrame(reader, 1<<10)
+	if err != nil {
+		t.Error(err)
+	}
+	var response Response
+	codec.Unmarshal(raw, &response)
+	assertExpectedVsActualAndClose(t, "Pung", response.Result.(string), nil)
+
+	time.Sleep(100 * time.Millisecond)
+	broadcast := `{"ID":"ID","Method":"Ping","Context":"Global","Result":"Pong","Error":null}`
+	server.Broadcast([]byte(broadcast))
+
+	raw, err = framer.ReadFrame(reader, 1<<10)
+	if err != nil {
+		t.Error(err)
+	}
+	response = Response{}
+	codec.Unmarshal(raw, &response)
+	assertExpectedVsActualAndClose(t, "Pong", response.Result.(string), nil)
+	assertExpectedVsActualAndClose(t, broadcast, receiveString(&plain), server)
+	plain.Close()
+}
+
+func Test_Codec_Unknown__Fail(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.codec"
+	request.Params = "xml"
+	expected := `{"ID":"ID","Method":"rpc.codec","Context":null,"Result":null,"Error":{"Code":-32602,"Message":"Invalid params","Data":{"Error":"Unknown codec xml","ID":"ID","Method":"rpc.codec"}}}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
+
+func Test_Codec_Msgpack_Decodes_Into_Target__OK(t *testing.T) {
+	type target struct {
+		Seq  uint64
+		Big  int64
+		Blob []byte
+		Raw  json.RawMessage
+	}
+	msg, err := MsgpackCodec{}.Marshal(map[string]interface{}{
+		"Seq":  uint64(1<<63 + 1),
+		"Big":  int64(-1<<62 - 1),
+		"Blob": []byte{0, 1, 2},
+		"Raw":  map[string]interface{}{"A": 1},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var actual target
+	if err := (MsgpackCodec{}).Unmarshal(msg, &actual); err != nil {
+		t.Error(err)
+		return
+	}
+	if actual.Seq != 1<<63+1 || actual.Big != -1<<62-1 ||
+		string(actual.Blob) != "\x00\x01\x02" || string(actual.Raw) != `{"A":1}` {
+		t.Errorf("unexpected %+v", actual)
+	}
+}
+
+func Test_Codec_Msgpack_Transcode_Keeps_Integers__OK(t *testing.T) {
+	server := &Server{}
+	msg := []byte(`{"Result":9007199254740993,"Seq":3}`)
+	encoded, err := server.transcode(msg,
+		wire{codec: MsgpackCodec{}}, map[string][]byte{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var response struct{ Result int64 }
+	if err := (MsgpackCodec{}).Unmarshal(encoded, &response); err != nil {
+		t.Error(err)
+		return
+	}
+	if response.Result != 9007199254740993 {
+		t.Errorf("expect 9007199254740993, actual %d", response.Result)
+	}
+}
+
+func Test_Codec_Msgpack_Too_Deep__Fail(t *testing.T) {
+	msg := make([]byte, 0, 2*maxMsgpackDepth)
+	for i := 0; i <= maxMsgpackDepth+1; i++ {
+		msg = append(msg, 0x91)
+	}
+	msg = append(msg, 0xc0)
+	var generic interface{}
+	if err := (MsgpackCodec{}).Unmarshal(msg, &generic); err != errMsgpackTooDeep {
+		t.Errorf("expect %v, actual %v", errMsgpackTooDeep, err)
+	}
+}
+
+func Test_Codec_Rejected_On_Full_Queue_Keeps_Wire__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	release := make(chan struct{})
+	server.RegisterSource("Block", "Global",
+		func(request *Request) (result interface{}, err error) {
+			<-release
+			return
+		})
+	scanner := bufio.NewScanner(conn)
+
+	// One request runs and maxQueuedRequests wait, so the queue is full
+	request := Request{}
+	request.Method = "Block"
+	request.Context = "Global"
+	for i := 0; i <= maxQueuedRequests; i++ {
+		request.ID = fmt.Sprint("Block ", i)
+		msg, _ := json.Marshal(&request)
+		send(&conn, msg)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	codec := Request{}
+	codec.ID = "ID"
+	codec.Method = "rpc.codec"
+	codec.Params = "msgpack"
+	msg, _ := json.Marshal(&codec)
+	send(&conn, msg)
+	scanner.Scan()
+	expected := `{"ID":"ID","Method":"rpc.codec","Context":null,"Result":null,"Error":{"Code":-32029,"Message":"Too many requests","Data":{"ID":"ID","Method":"rpc.codec"}}}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+
+	// The conn still reads newline delimited JSON
+	ping := Request{}
+	ping.ID = "Ping"
+	ping.Method = "rpc.ping"
+	msg, _ = json.Marshal(&ping)
+	send(&conn, msg)
+	scanner.Scan()
+	expected = `{"ID":"Ping","Method":"rpc.ping","Context":null,"Result":"pong","Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+
+	close(release)
+	conn.Close()
+	server.Close()
+}
@@ -0,0 +1,96 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"net"
+)
+
+// compress compresses msg with the given algorithm, deflate or gzip. An
+// empty algorithm leaves msg untouched
+func (s *Server) compress(msg []byte, algorithm string) ([]byte, error) {
+	level := s.CompressionLevel
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch algorithm {
+	case "":
+		return msg, nil
+	case "deflate":
+		var writer *flate.Writer
+		if writer, err = flate.NewWriter(&buf, level); err != nil {
+			return nil, err
+		}
+		if _, err = writer.Write(msg); err == nil {
+			err = writer.Close()
+		}
+	case "gzip":
+		var writer *gzip.Writer
+		if writer, err = gzip.NewWriterLevel(&buf, level); err != nil {
+			return nil, err
+		}
+		if _, err = writer.Write(msg); err == nil {
+			err = writer.Close()
+		}
+	default:
+		err = fmt.Errorf("Unknown compression %s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// processCompress answers the reserved method rpc.compress, whose Params is
+// deflate, gzip or an empty string to stop compressing. From then on every
+// message sent to the conn is compressed on its own, so a broadcast is
+// compressed once for all the conns that share the same codec. The answer
+// is not compressed yet and, as with binary codecs, the conn switches to
+// the ContentLengthFramer. It returns false if the request is not
+// rpc.compress
+func (s *Server) processCompress(request *Request, conn net.Conn) bool {
+	if request.Method != "rpc.compress" {
+		return false
+	}
+	algorithm, _ := request.Params.(string)
+	if _, err := s.compress(nil, algorithm); err != nil {
+		if err := s.sendError(conn, &request.Base, &Error{
+			Message: "Invalid params",
+			Code:    -32602,
+			Data: map[string]string{
+				"Method": request.Method,
+				"ID":     request.ID,
+				"Error":  fmt.Sprint(err),
+			},
+		}); err != nil {
+			//log.Println("processCompress:sendError", err)
+		}
+		return true
+	}
+
+	if err := s.send(conn, &Response{
+		Base:   request.Base,
+		Result: algorithm,
+	}); err != nil {
+		//log.Println("processCompress:send", err)
+	}
+	session := s.session(conn)
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	session.wire = session.wire.withCompression(algorithm)
+	return true
+}
+
+// withCompression returns the wire that compresses with the given algorithm
+func (w wire) withCompression(algorithm string) wire {
+	w.compression = algorithm
+	if algorithm != "" {
+		w.framer = binaryFramer(w.framer)
+	}
+	return w
+}
@@ -0,0 +1,54 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"io"
+	"testing"
+	"time"
+)
+
+func Test_Compress_Deflate_Broadcast__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.compress"
+	request.Params = "deflate"
+	expected := `{"ID":"ID","Method":"rpc.compress","Context":null,"Result":"deflate","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	time.Sleep(100 * time.Millisecond)
+	broadcast := `{"ID":"ID","Method":"Ping","Context":"Global","Result":"Pong","Error":null}`
+	server.Broadcast([]byte(broadcast))
+
+	raw, err := ContentLengthFramer{}.ReadFrame(bufio.NewReader(conn), 1<<10)
+	if err != nil {
+		t.Error(err)
+	}
+	decompressed, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Error(err)
+	}
+	assertExpectedVsActualAndClose(t, broadcast, string(decompressed), server)
+}
+
+func Test_Compress_Unknown__Fail(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.compress"
+	request.Params = "brotli"
+	expected := `{"ID":"ID","Method":"rpc.compress","Context":null,"Result":null,"Error":{"Code":-32602,"Message":"Invalid params","Data":{"Error":"Unknown compression brotli","ID":"ID","Method":"rpc.compress"}}}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
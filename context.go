@@ -0,0 +1,63 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var errContextPath = errors.New("Incorrect context path")
+
+// contextPath validates the value of a Source or Target field. A context is
+// a path whose segments are separated by slashes, like project/table, and
+// it may also be given as the list of its segments
+func contextPath(value interface{}) (string, error) {
+	var segments []string
+	switch value := value.(type) {
+	case string:
+		if value == "" {
+			return "", nil
+		}
+		segments = strings.Split(value, "/")
+	case []interface{}:
+		for _, segment := range value {
+			segment, ok := segment.(string)
+			if !ok || strings.Contains(segment, "/") {
+				return "", errContextPath
+			}
+			segments = append(segments, segment)
+		}
+	default:
+		return "", errContextPath
+	}
+	if len(segments) == 0 {
+		return "", errContextPath
+	}
+	for _, segment := range segments {
+		if segment == "" {
+			return "", errContextPath
+		}
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// parentContext returns the parent of the given context, so a context
+// inherits the handlers of its parents. For project/table it returns
+// project. It returns false if the context has no parent
+func parentContext(context string) (string, bool) {
+	slash := strings.LastIndexByte(context, '/')
+	if slash == -1 {
+		return "", false
+	}
+	return context[:slash], true
+}
+
+// DecodeContext decodes the Context of the request into v, which is usually
+// a pointer to a struct with the fields that the handler expects
+func (request *Request) DecodeContext(v interface{}) error {
+	raw, err := json.Marshal(request.Context)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,68 @@
+package jsonrpc
+
+import (
+	"testing"
+)
+
+func Test_Context_Inherits_From_Parent__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	server.RegisterSource("Ping", "Project",
+		func(request *Request) (result interface{}, err error) {
+			result = "Pong from Project"
+			return
+		})
+	server.RegisterSource("Ping", "Project/Table",
+		func(request *Request) (result interface{}, err error) {
+			result = "Pong from Table"
+			return
+		})
+	server.RegisterSource("Count", "Project",
+		func(request *Request) (result interface{}, err error) {
+			context := struct {
+				Source []string
+				Rows   int
+			}{}
+			err = request.DecodeContext(&context)
+			result = context
+			return
+		})
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Ping"
+	request.Context = map[string]interface{}{"Source": "Project/Table"}
+	expected := `{"ID":"ID","Method":"Ping","Context":{"Source":"Project/Table"},"Result":"Pong from Table","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	request.Method = "Count"
+	request.Context = map[string]interface{}{
+		"Source": []string{"Project", "Table", "Rows"}, "Rows": 3}
+	expected = `{"ID":"ID","Method":"Count","Context":{"Rows":3,"Source":["Project","Table","Rows"]},"Result":{"Source":["Project","Table","Rows"],"Rows":3},"Error":null}`
+	actual = sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
+
+func Test_Context_Malformed__Error(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Ping"
+	request.Context = map[string]interface{}{"Source": 434}
+	expected := `{"ID":"ID","Method":"Ping","Context":{"Source":434},"Result":null,"Error":{"Code":-32600,"Message":"Invalid Request","Data":{"Error":"Incorrect context map[Source:434]","ID":"ID","Method":"Ping"}}}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	request.Context = "Project//Table"
+	expected = `{"ID":"ID","Method":"Ping","Context":"Project//Table","Result":null,"Error":{"Code":-32600,"Message":"Invalid Request","Data":{"Error":"Incorrect context Project//Table","ID":"ID","Method":"Ping"}}}`
+	actual = sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
@@ -0,0 +1,101 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var errRequestTimeout = errors.New("Request timed out")
+
+// Ctx returns the context of the request. It is done when the deadline of
+// the request passes, so long handlers should stop then
+func (request *Request) Ctx() context.Context {
+	if request.ctx == nil {
+		return context.Background()
+	}
+	return request.ctx
+}
+
+// timeout returns how long the request may run. The Timeout of the request
+// in milliseconds goes first, then RequestTimeout, and both are capped by
+// the MethodTimeouts of the method. Zero means no deadline
+func (s *Server) timeout(request *Request) time.Duration {
+	timeout := s.RequestTimeout
+	if request.Timeout > 0 {
+		timeout = time.Duration(request.Timeout) * time.Millisecond
+	}
+	if max := s.MethodTimeouts[request.Method]; max > 0 &&
+		(timeout <= 0 || timeout > max) {
+		timeout = max
+	}
+	return timeout
+}
+
+// withDeadline gives the request a context that is done after its timeout
+// or, if cancellable, when it is cancelled. Requests without both keep the
+// background context
+func (s *Server) withDeadline(
+	request *Request, cancellable bool) context.CancelFunc {
+	var cancel context.CancelFunc
+	if timeout := s.timeout(request); timeout > 0 {
+		request.ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else if cancellable {
+		request.ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		cancel = func() {}
+	}
+	return cancel
+}
+
+// invoke runs the handler and recovers its panics. When the context of the
+// request is done, because of its deadline or because it was cancelled,
+// invoke returns at once so the error is answered in time, and the result
+// the handler returns later is dropped. The handler is not abandoned: wait
+// blocks until it returns, so a conn keeps running one handler at a time
+func invoke(request *Request,
+	handler RemoteProcedure) (result interface{}, err error) {
+	call := func() (result interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+		return handler(request)
+	}
+	ctx := request.Ctx()
+	if ctx.Done() == nil {
+		return call()
+	}
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	request.running = make(chan struct{})
+	go func() {
+		defer close(request.running)
+		result, err := call()
+		done <- outcome{result, err}
+	}()
+	select {
+	case o := <-done:
+		if ctx.Err() == nil {
+			return o.result, o.err
+		}
+	case <-ctx.Done():
+	}
+	if ctx.Err() == context.Canceled {
+		return nil, errRequestCancelled
+	}
+	return nil, errRequestTimeout
+}
+
+// wait blocks until the handler started by invoke returns
+func (request *Request) wait() {
+	if request.running != nil {
+		<-request.running
+	}
+}
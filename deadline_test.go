@@ -0,0 +1,114 @@
+package jsonrpc
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Deadline_Request_Timeout__Error(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	stopped := make(chan bool, 1)
+	server.RegisterSource("Slow", "Report",
+		func(request *Request) (result interface{}, err error) {
+			select {
+			case <-request.Ctx().Done():
+				stopped <- true
+			case <-time.After(time.Second):
+				stopped <- false
+			}
+			result = "Done"
+			return
+		})
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Slow"
+	request.Context = "Report"
+	request.Timeout = 100
+	expected := `{"ID":"ID","Method":"Slow","Context":"Report","Result":null,"Error":{"Code":-32002,"Message":"Request timed out","Data":{"ID":"ID","Method":"Slow","Timeout":100}}}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+	if !<-stopped {
+		t.Error("The context of the handler was not cancelled")
+	}
+}
+
+func Test_Deadline_Method_Maximum__Error(t *testing.T) {
+	server, conn, err := startGivenServerAndClient(t, &Server{
+		Address:        address,
+		RequestTimeout: time.Second,
+		MethodTimeouts: map[string]time.Duration{
+			"Slow": 50 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	server.RegisterSource("Slow", "Report",
+		func(request *Request) (result interface{}, err error) {
+			<-request.Ctx().Done()
+			return
+		})
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Slow"
+	request.Context = "Report"
+	request.Timeout = 10000
+	expected := `{"ID":"ID","Method":"Slow","Context":"Report","Result":null,"Error":{"Code":-32002,"Message":"Request timed out","Data":{"ID":"ID","Method":"Slow","Timeout":50}}}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+	conn.Close()
+}
+
+func Test_Deadline_Handler_Ignores_Ctx__Error(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	finished := make(chan time.Time, 1)
+	server.RegisterSource("Slow", "Report",
+		func(request *Request) (result interface{}, err error) {
+			time.Sleep(500 * time.Millisecond)
+			finished <- time.Now()
+			result = "Done"
+			return
+		})
+	server.RegisterSource("Fast", "Report",
+		func(request *Request) (result interface{}, err error) {
+			result = "Fast"
+			return
+		})
+
+	start := time.Now()
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Slow"
+	request.Context = "Report"
+	request.Timeout = 50
+	expected := `{"ID":"ID","Method":"Slow","Context":"Report","Result":null,"Error":{"Code":-32002,"Message":"Request timed out","Data":{"ID":"ID","Method":"Slow","Timeout":50}}}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Error("The timeout was answered late", elapsed)
+	}
+
+	// The next request waits for the slow handler to return
+	request.ID = "Next"
+	request.Method = "Fast"
+	request.Timeout = 0
+	expected = `{"ID":"Next","Method":"Fast","Context":"Report","Result":"Fast","Error":null}`
+	actual = sendJSONAndReceive(&conn, &request)
+	answered := time.Now()
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+	if returned := <-finished; answered.Before(returned) {
+		t.Error("The next request ran before the slow handler returned")
+	}
+	conn.Close()
+}
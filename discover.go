@@ -0,0 +1,215 @@
+package jsonrpc
+
+import (
+	"net"
+	"sort"
+)
+
+// MethodDescription documents a registered method. Params and Result are
+// JSON schemas of the request params and of the response result
+type MethodDescription struct {
+	Summary     string
+	Description string
+	Params      interface{}
+	Result      interface{}
+}
+
+// MethodInfo is a registered method as listed by rpc.methods
+type MethodInfo struct {
+	Source  string
+	Context string
+	Method  string
+	MethodDescription
+}
+
+// OpenRPCInfo is the info object of an OpenRPC document
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCContentDescriptor describes the params or the result of a method
+type OpenRPCContentDescriptor struct {
+	Name   string      `json:"name"`
+	Schema interface{} `json:"schema"`
+}
+
+// OpenRPCMethod is a method of an OpenRPC document. The name joins the
+// context and the method with a dot. Broadcast marks the Target methods,
+// whose result is broadcasted instead of answered, and whose name ends with
+// .broadcast to keep the names unique
+type OpenRPCMethod struct {
+	Name        string                     `json:"name"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Params      []OpenRPCContentDescriptor `json:"params"`
+	Result      *OpenRPCContentDescriptor  `json:"result,omitempty"`
+	Context     string                     `json:"x-arca-context"`
+	Method      string                     `json:"x-arca-method"`
+	Broadcast   bool                       `json:"x-arca-broadcast,omitempty"`
+}
+
+// OpenRPCDocument is the document returned by rpc.discover
+type OpenRPCDocument struct {
+	OpenRPC string          `json:"openrpc"`
+	Info    OpenRPCInfo     `json:"info"`
+	Methods []OpenRPCMethod `json:"methods"`
+}
+
+// DescribeSource attaches a description to a method registered with
+// RegisterSource
+func (s *Server) DescribeSource(
+	method string, context string, description MethodDescription) {
+	s.describe("Source", method, context, description)
+}
+
+// DescribeTarget attaches a description to a method registered with
+// RegisterTarget. Its Result describes what is broadcasted
+func (s *Server) DescribeTarget(
+	method string, context string, description MethodDescription) {
+	s.describe("Target", method, context, description)
+}
+
+func (s *Server) describe(
+	source, method, context string, description MethodDescription) {
+	s.updateRegistry(func(r *registry) {
+		r.descriptions[descriptionKey(source, context, method)] = description
+	})
+}
+
+// Methods lists the registered methods and their descriptions sorted by
+// source, context and method
+func (s *Server) Methods() []MethodInfo {
+	return s.methods(nil)
+}
+
+// methods lists the registered methods, leaving out the Source ones whose
+// context is not reachable from the given conn
+func (s *Server) methods(conn net.Conn) []MethodInfo {
+	r := s.registry()
+	infos := make([]MethodInfo, 0)
+	for context, rps := range r.source {
+		if !s.reachable(conn, context) {
+			continue
+		}
+		for method := range rps {
+			infos = append(infos, r.methodInfo("Source", context, method))
+		}
+	}
+	for context, rps := range r.target {
+		for method := range rps {
+			infos = append(infos, r.methodInfo("Target", context, method))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Context != b.Context {
+			return a.Context < b.Context
+		}
+		return a.Method < b.Method
+	})
+	return infos
+}
+
+func (r *registry) methodInfo(source, context, method string) MethodInfo {
+	return MethodInfo{
+		Source:            source,
+		Context:           context,
+		Method:            method,
+		MethodDescription: r.descriptions[descriptionKey(source, context, method)],
+	}
+}
+
+// OpenRPC builds the OpenRPC document of the registered methods
+func (s *Server) OpenRPC() *OpenRPCDocument {
+	return s.openRPC(s.methods(nil))
+}
+
+func (s *Server) openRPC(infos []MethodInfo) *OpenRPCDocument {
+	info := s.Info
+	if info.Title == "" {
+		info.Title = "arca-jsonrpc"
+	}
+	if info.Version == "" {
+		info.Version = "0.0.0"
+	}
+	document := &OpenRPCDocument{
+		OpenRPC: "1.2.6",
+		Info:    info,
+		Methods: make([]OpenRPCMethod, 0, len(infos)),
+	}
+	for _, mi := range infos {
+		name := mi.Context + "." + mi.Method
+		if mi.Source == "Target" {
+			name += ".broadcast"
+		}
+		method := OpenRPCMethod{
+			Name:        name,
+			Summary:     mi.Summary,
+			Description: mi.Description,
+			Params: []OpenRPCContentDescriptor{
+				{Name: "Params", Schema: schemaOrAny(mi.Params)},
+			},
+			Result: &OpenRPCContentDescriptor{
+				Name: "Result", Schema: schemaOrAny(mi.Result),
+			},
+			Context:   mi.Context,
+			Method:    mi.Method,
+			Broadcast: mi.Source == "Target",
+		}
+		document.Methods = append(document.Methods, method)
+	}
+	return document
+}
+
+// schemaOrAny returns the given schema or the schema that accepts anything
+func schemaOrAny(schema interface{}) interface{} {
+	if schema == nil {
+		return map[string]interface{}{}
+	}
+	return schema
+}
+
+// processDiscover answers the reserved methods of introspection.
+// rpc.contexts returns the reachable contexts and their Source methods,
+// rpc.methods returns the descriptions of the methods of the context given
+// in Params, or of all of them if Params is empty, and rpc.discover returns
+// the OpenRPC document. It returns false if the request is not one of them
+func (s *Server) processDiscover(request *Request, conn net.Conn) bool {
+	var result interface{}
+	switch request.Method {
+	case "rpc.contexts":
+		contexts := make(map[string][]string)
+		for _, info := range s.methods(conn) {
+			if info.Source == "Source" {
+				contexts[info.Context] = append(
+					contexts[info.Context], info.Method)
+			}
+		}
+		result = contexts
+	case "rpc.methods":
+		context, _ := request.Params.(string)
+		infos := make([]MethodInfo, 0)
+		for _, info := range s.methods(conn) {
+			if context == "" || info.Context == context {
+				infos = append(infos, info)
+			}
+		}
+		result = infos
+	case "rpc.discover":
+		result = s.openRPC(s.methods(conn))
+	default:
+		return false
+	}
+
+	if err := s.send(conn, &Response{
+		Base:   request.Base,
+		Result: result,
+	}); err != nil {
+		//log.Println("processDiscover:send", err)
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package jsonrpc
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func Test_Discover_Contexts_Methods_And_OpenRPC__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	pung :=
+		func(request *Request) (result interface{}, err error) {
+			result = "Pung"
+			return
+		}
+	notify :=
+		func(db *sql.DB) RemoteProcedure {
+			return pung
+		}
+	server.RegisterSource("Pung", "Global", pung)
+	server.RegisterSource("Ping", "Global", pung)
+	server.RegisterTarget("Pung", "Global", notify)
+	server.DescribeSource("Pung", "Global", MethodDescription{
+		Summary: "Answers Pung",
+		Result:  map[string]interface{}{"type": "string"},
+	})
+
+	request := Request{}
+	request.ID = "ID"
+
+	request.Method = "rpc.contexts"
+	expected := `{"ID":"ID","Method":"rpc.contexts","Context":null,"Result":{"Global":["Ping","Pung"]},"Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	request.Method = "rpc.methods"
+	request.Params = "Global"
+	expected = `{"ID":"ID","Method":"rpc.methods","Context":null,"Result":[` +
+		`{"Source":"Source","Context":"Global","Method":"Ping","Summary":"","Description":"","Params":null,"Result":null},` +
+		`{"Source":"Source","Context":"Global","Method":"Pung","Summary":"Answers Pung","Description":"","Params":null,"Result":{"type":"string"}},` +
+		`{"Source":"Target","Context":"Global","Method":"Pung","Summary":"","Description":"","Params":null,"Result":null}],"Error":null}`
+	actual = sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	request.Method = "rpc.discover"
+	request.Params = nil
+	expected = `{"ID":"ID","Method":"rpc.discover","Context":null,"Result":{"openrpc":"1.2.6","info":{"title":"arca-jsonrpc","version":"0.0.0"},"methods":[` +
+		`{"name":"Global.Ping","params":[{"name":"Params","schema":{}}],"result":{"name":"Result","schema":{}},"x-arca-context":"Global","x-arca-method":"Ping"},` +
+		`{"name":"Global.Pung","summary":"Answers Pung","params":[{"name":"Params","schema":{}}],"result":{"name":"Result","schema":{"type":"string"}},"x-arca-context":"Global","x-arca-method":"Pung"},` +
+		`{"name":"Global.Pung.broadcast","params":[{"name":"Params","schema":{}}],"result":{"name":"Result","schema":{}},"x-arca-context":"Global","x-arca-method":"Pung","x-arca-broadcast":true}]},"Error":null}`
+	actual = sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
@@ -0,0 +1,111 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxHeaderSize is the longest header line a ContentLengthFramer accepts
+const maxHeaderSize = 1 << 10
+
+// ErrFrameHeader is returned by a Framer when it cannot find where a
+// message ends. The stream is out of sync and the conn gets closed
+var ErrFrameHeader = errors.New("Invalid frame header")
+
+// Framer splits a stream into messages and writes messages into a stream.
+// ReadFrame returns ErrRequestTooLarge when a message is larger than max
+// bytes, after discarding it so the next message can be read, and
+// ErrFrameHeader when the stream cannot be split anymore
+type Framer interface {
+	ReadFrame(reader *bufio.Reader, max int) ([]byte, error)
+	WriteFrame(writer io.Writer, msg []byte) error
+}
+
+// NewlineFramer delimits every message with a newline. It is the default
+type NewlineFramer struct{}
+
+// ReadFrame reads a message up to the next newline
+func (NewlineFramer) ReadFrame(reader *bufio.Reader, max int) ([]byte, error) {
+	return readLine(reader, max)
+}
+
+// WriteFrame writes the message followed by a newline
+func (NewlineFramer) WriteFrame(writer io.Writer, msg []byte) error {
+	frame := make([]byte, 0, len(msg)+1)
+	frame = append(frame, msg...)
+	frame = append(frame, '\n')
+	_, err := writer.Write(frame)
+	return err
+}
+
+// ContentLengthFramer precedes every message with a Content-Length header,
+// as the Language Server Protocol does, so messages may span several lines
+type ContentLengthFramer struct{}
+
+// ReadFrame reads the headers of a message and then its body
+func (ContentLengthFramer) ReadFrame(
+	reader *bufio.Reader, max int) ([]byte, error) {
+	length := -1
+	for {
+		line, err := readLine(reader, maxHeaderSize)
+		if err == ErrRequestTooLarge {
+			return nil, ErrFrameHeader
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			if length == -1 {
+				continue
+			}
+			break
+		}
+		colon := bytes.IndexByte(line, ':')
+		if colon == -1 {
+			return nil, ErrFrameHeader
+		}
+		name := strings.TrimSpace(string(line[:colon]))
+		if !strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+		length, err = strconv.Atoi(strings.TrimSpace(string(line[colon+1:])))
+		if err != nil || length < 0 {
+			return nil, ErrFrameHeader
+		}
+	}
+
+	if length > max {
+		if _, err := io.CopyN(io.Discard, reader, int64(length)); err != nil {
+			return nil, err
+		}
+		return nil, ErrRequestTooLarge
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// WriteFrame writes the Content-Length header followed by the message
+func (ContentLengthFramer) WriteFrame(writer io.Writer, msg []byte) error {
+	header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(msg))
+	frame := make([]byte, 0, len(header)+len(msg))
+	frame = append(frame, header...)
+	frame = append(frame, msg...)
+	_, err := writer.Write(frame)
+	return err
+}
+
+// framer returns the Framer of the server or its default
+func (s *Server) framer() Framer {
+	if s.Framer != nil {
+		return s.Framer
+	}
+	return NewlineFramer{}
+}
@@ -0,0 +1,95 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_Framer_ContentLength_Pretty_JSON__OK(t *testing.T) {
+	server := &Server{Address: address, Framer: ContentLengthFramer{}}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+
+	pung :=
+		func(request *Request) (result interface{}, err error) {
+			result = "Pung"
+			return
+		}
+	server.RegisterSource("Pung", "Global", pung)
+
+	request := "{\n  \"ID\": \"ID\",\n  \"Method\": \"Pung\",\n  \"Context\": \"Global\"\n}"
+	ContentLengthFramer{}.WriteFrame(conn, []byte(request))
+
+	actual, err := ContentLengthFramer{}.ReadFrame(bufio.NewReader(conn), 1<<10)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := `{"ID":"ID","Method":"Pung","Context":"Global","Result":"Pung","Error":null}`
+	assertExpectedVsActualAndClose(t, expected, string(actual), server)
+}
+
+func Test_Framer_ContentLength_Too_Large_Then_Next__OK(t *testing.T) {
+	var stream bytes.Buffer
+	framer := ContentLengthFramer{}
+	framer.WriteFrame(&stream, []byte(strings.Repeat("x", 64)))
+	framer.WriteFrame(&stream, []byte("next"))
+
+	reader := bufio.NewReader(&stream)
+	if _, err := framer.ReadFrame(reader, 16); err != ErrRequestTooLarge {
+		t.Errorf("expect %v, actual %v", ErrRequestTooLarge, err)
+	}
+	actual, err := framer.ReadFrame(reader, 16)
+	if err != nil {
+		t.Error(err)
+	}
+	assertExpectedVsActualAndClose(t, "next", string(actual), nil)
+}
+
+func Test_Framer_ContentLength_Invalid_Header__Fail(t *testing.T) {
+	reader := bufio.NewReader(
+		strings.NewReader("Content-Length: abc\r\n\r\n"))
+	if _, err := (ContentLengthFramer{}).ReadFrame(
+		reader, 16); err != ErrFrameHeader {
+		t.Errorf("expect %v, actual %v", ErrFrameHeader, err)
+	}
+}
+
+func Test_Framer_ContentLength_Invalid_Header_Closes__OK(t *testing.T) {
+	server := &Server{Address: address, Framer: ContentLengthFramer{}}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+
+	conn.Write([]byte("Content-Length: abc\r\n\r\n"))
+	reader := bufio.NewReader(conn)
+	actual, err := ContentLengthFramer{}.ReadFrame(reader, 1<<10)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := `{"ID":"","Method":"","Context":null,"Result":null,"Error":{"Code":-32700,"Message":"Parse error","Data":"Invalid frame header"}}`
+	assertExpectedVsActualAndClose(t, expected, string(actual), nil)
+
+	if _, err := reader.ReadByte(); err == nil {
+		t.Error("Expecting the conn to be closed")
+	}
+	conn.Close()
+	server.Close()
+}
@@ -0,0 +1,95 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+)
+
+var errConnIDNotFound = errors.New("Connection not found")
+
+// ConnID returns the ID of the connection that sent the request, or an
+// empty string if the request did not come from a connection
+func (request *Request) ConnID() string {
+	return request.connID
+}
+
+// nextConnID returns a new ID of connection. It is called while plugging
+// the conn, so plugBlocker is held
+func (s *Server) nextConnID() string {
+	s.lastConnID++
+	return strconv.FormatUint(s.lastConnID, 10)
+}
+
+// sessionByID returns the state of the connection with the given ID, or nil
+// if there is no such connection
+func (s *Server) sessionByID(connID string) *session {
+	s.plugBlocker.Lock()
+	defer s.plugBlocker.Unlock()
+	for _, session := range s.sessions {
+		if session.id == connID {
+			return session
+		}
+	}
+	return nil
+}
+
+// Join adds the connection with the given ID to the named group. A
+// connection leaves all its groups when it is closed
+func (s *Server) Join(connID string, group string) error {
+	session := s.sessionByID(connID)
+	if session == nil {
+		return errConnIDNotFound
+	}
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	session.groups[group] = true
+	return nil
+}
+
+// Leave removes the connection with the given ID from the named group
+func (s *Server) Leave(connID string, group string) error {
+	session := s.sessionByID(connID)
+	if session == nil {
+		return errConnIDNotFound
+	}
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	delete(session.groups, group)
+	return nil
+}
+
+// inGroup tells if the session joined the named group
+func (session *session) inGroup(group string) bool {
+	session.blocker.Lock()
+	defer session.blocker.Unlock()
+	return session.groups[group]
+}
+
+// SendTo sends the given response to the connection with the given ID
+func (s *Server) SendTo(connID string, response *Response) error {
+	session := s.sessionByID(connID)
+	if session == nil {
+		return errConnIDNotFound
+	}
+	return s.send(session.conn, response)
+}
+
+// BroadcastToGroup sends the given response to all the connections of the
+// named group
+func (s *Server) BroadcastToGroup(group string, response *Response) {
+	msg, err := json.Marshal(response)
+	if err != nil {
+		//log.Println("BroadcastToGroup", err)
+		return
+	}
+	conns := make([]net.Conn, 0)
+	for _, conn := range s.connections() {
+		session := s.session(conn)
+		if session != nil && session.inGroup(group) {
+			conns = append(conns, conn)
+		}
+	}
+	s.fanOut(msg, conns)
+}
@@ -0,0 +1,57 @@
+package jsonrpc
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Group_SendTo_And_BroadcastToGroup__OK(t *testing.T) {
+	server, conn1, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+	conn2, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+	}
+
+	server.RegisterSource("Join", "Project",
+		func(request *Request) (result interface{}, err error) {
+			err = server.Join(request.ConnID(), "Project 1")
+			result = request.ConnID()
+			return
+		})
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Join"
+	request.Context = "Project"
+	expected := `{"ID":"ID","Method":"Join","Context":"Project","Result":"1","Error":null}`
+	actual := sendJSONAndReceive(&conn1, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	response := &Response{}
+	response.Method = "Updated"
+	response.Context = "Project"
+	response.Result = "Project 1"
+	server.BroadcastToGroup("Project 1", response)
+	expected = `{"ID":"","Method":"Updated","Context":"Project","Result":"Project 1","Error":null}`
+	actual = receiveString(&conn1)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	response.Result = "Only for 2"
+	if err := server.SendTo("2", response); err != nil {
+		t.Error(err)
+	}
+	conn2.SetReadDeadline(time.Now().Add(time.Second))
+	expected = `{"ID":"","Method":"Updated","Context":"Project","Result":"Only for 2","Error":null}`
+	actual = receiveString(&conn2)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	if err := server.SendTo("3", response); err != errConnIDNotFound {
+		t.Error("Expected errConnIDNotFound, got", err)
+	}
+	conn2.Close()
+	server.Close()
+}
@@ -2,9 +2,9 @@ package jsonrpc
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"net"
+	"strings"
 )
 
 // RegisterSource stores the hierarchy of the handlers agains their
@@ -13,13 +13,14 @@ import (
 // to the set of JSON-RPs from the client side.
 func (s *Server) RegisterSource(
 	method string, context string, rp RemoteProcedure) {
-	if s.registersSource[context] == nil {
-		rps := make(map[string]RemoteProcedure)
+	s.updateRegistry(func(r *registry) {
+		rps := make(map[string]RemoteProcedure, len(r.source[context])+1)
+		for name, registered := range r.source[context] {
+			rps[name] = registered
+		}
 		rps[method] = rp
-		s.registersSource[context] = rps
-	} else {
-		s.registersSource[context][method] = rp
-	}
+		r.source[context] = rps
+	})
 }
 
 // RegisterTarget stores the hierarchy of the handlers agains their
@@ -28,13 +29,14 @@ func (s *Server) RegisterSource(
 // to the set of JSON-RPs from the client side.
 func (s *Server) RegisterTarget(
 	method string, context string, rp DBRemoteProcedure) {
-	if s.registersTarget[context] == nil {
-		rps := make(map[string]DBRemoteProcedure)
+	s.updateRegistry(func(r *registry) {
+		rps := make(map[string]DBRemoteProcedure, len(r.target[context])+1)
+		for name, registered := range r.target[context] {
+			rps[name] = registered
+		}
 		rps[method] = rp
-		s.registersTarget[context] = rps
-	} else {
-		s.registersTarget[context][method] = rp
-	}
+		r.target[context] = rps
+	})
 }
 
 // handleClient listens for any messages from conn and process it by using
@@ -42,17 +44,73 @@ func (s *Server) RegisterTarget(
 func (s *Server) handleClient(conn net.Conn) {
 	defer conn.Close()
 
-	scanner := bufio.NewScanner(conn)
-	for scanner.Scan() {
-		raw := scanner.Bytes()
+	session := s.session(conn)
+	reader := bufio.NewReader(conn)
+	go s.writeQueued(conn, session)
+
+	// The requests are handled in order by another goroutine, so this one
+	// keeps reading the cancellations, the stream acks and the responses to
+	// Call. The methods that change the wire are queued with the requests,
+	// so their answer follows the previous ones, but the reader switches to
+	// the new wire at once
+	queue := make(chan *Request, maxQueuedRequests)
+	stopping := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for request := range queue {
+			select {
+			case <-stopping:
+			default:
+				if !s.processCodec(request, conn) &&
+					!s.processCompress(request, conn) {
+					s.ProcessRequest(request, conn)
+				}
+			}
+		}
+	}()
+	w := s.encoding(conn)
+	for {
+		s.extendDeadline(conn)
+		raw, err := w.framer.ReadFrame(reader, s.maxRequestSize())
+		if err == ErrRequestTooLarge {
+			session.touch()
+			s.metrics.observeRequest("Source", "", "", -32600)
+			if err1 := s.sendError(conn, &Base{}, &Error{
+				Message: "Invalid Request",
+				Code:    -32600,
+				Data: fmt.Sprintf(
+					"Request larger than %d bytes", s.maxRequestSize()),
+			}); err1 != nil {
+				//log.Println("handleClient:readLine:sendError", err1)
+			}
+			continue
+		}
+		if err == ErrFrameHeader {
+			session.touch()
+			s.metrics.observeRequest("Source", "", "", -32700)
+			if err1 := s.sendError(conn, &Base{}, &Error{
+				Message: "Parse error",
+				Code:    -32700,
+				Data:    fmt.Sprint(err),
+			}); err1 != nil {
+				//log.Println("handleClient:ReadFrame:sendError", err1)
+			}
+			break
+		}
+		if err != nil {
+			break
+		}
+		session.touch()
 		if len(raw) == 0 {
 			continue
 		}
 		//log.Println("Request:", string(raw))
 		var request Request
 
-		if err := json.Unmarshal(raw, &request); err != nil {
+		if err := w.codec.Unmarshal(raw, &request); err != nil {
 			//log.Println("handleClient:Unmarshal", err)
+			s.metrics.observeRequest("Source", "", "", -32700)
 			base := &Base{}
 			if err1 := s.sendError(conn, base, &Error{
 				Message: "Parse error",
@@ -64,7 +122,40 @@ func (s *Server) handleClient(conn net.Conn) {
 			continue
 		}
 
-		s.ProcessRequest(&request, conn)
+		if s.processResponse(raw, w, &request, session) ||
+			s.processCancel(&request, session) ||
+			s.processStreamAck(&request, session) {
+			continue
+		}
+		if strings.HasPrefix(request.Method, "rpc.") {
+			if s.processHeartbeat(&request, conn) ||
+				s.processDiscover(&request, conn) ||
+				s.processReplay(&request, conn) ||
+				s.processNotifications(&request, conn) {
+				continue
+			}
+		}
+		select {
+		case queue <- &request:
+			w = s.switchWire(&request, w)
+		default:
+			s.metrics.observeRequest("Source", "", "", -32029)
+			if err := s.sendError(conn, &request.Base, &Error{
+				Message: "Too many requests",
+				Code:    -32029,
+				Data: map[string]string{
+					"Method": request.Method,
+					"ID":     request.ID,
+				},
+			}); err != nil {
+				//log.Println("handleClient:queue:sendError", err)
+			}
+		}
 	}
+	close(stopping)
+	close(session.stopped)
+	session.cancelAll()
+	close(queue)
+	<-stopped
 	//log.Println("disconnected")
 }
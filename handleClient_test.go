@@ -0,0 +1,81 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_HandleClient_Oversized_Request_Keeps_Conn__OK(t *testing.T) {
+	server := &Server{Address: address, MaxRequestSize: 128}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+
+	pung :=
+		func(request *Request) (result interface{}, err error) {
+			result = "Pung"
+			return
+		}
+	server.RegisterSource("Pung", "Global", pung)
+
+	reader := bufio.NewReader(conn)
+	send(&conn, []byte(`{"ID":"ID","Method":"Pung","Context":"Global","Params":"`+
+		strings.Repeat("x", 256)+`"}`))
+	expected := `{"ID":"","Method":"","Context":null,"Result":null,"Error":{"Code":-32600,"Message":"Invalid Request","Data":"Request larger than 128 bytes"}}`
+	actual, _ := readLine(reader, 1<<10)
+	assertExpectedVsActualAndClose(t, expected, string(actual), nil)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Pung"
+	request.Context = "Global"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+	expected = `{"ID":"ID","Method":"Pung","Context":"Global","Result":"Pung","Error":null}`
+	actual, _ = readLine(reader, 1<<10)
+	assertExpectedVsActualAndClose(t, expected, string(actual), server)
+}
+
+func Test_HandleClient_Large_Request_And_Result__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	echo :=
+		func(request *Request) (result interface{}, err error) {
+			result = strings.Repeat(request.Params.(string), 4)
+			return
+		}
+	server.RegisterSource("Echo", "Global", echo)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Echo"
+	request.Context = "Global"
+	request.Params = strings.Repeat("x", 256<<10)
+
+	reader := bufio.NewReader(conn)
+	msg, _ := json.Marshal(&request)
+	go send(&conn, msg)
+	actual, err := readLine(reader, 2<<20)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := `{"ID":"ID","Method":"Echo","Context":"Global","Result":"` +
+		strings.Repeat("x", 1<<20) + `","Error":null}`
+	if expected != string(actual) {
+		t.Errorf("expect %d bytes, actual %d bytes", len(expected), len(actual))
+	}
+	server.Close()
+}
@@ -0,0 +1,108 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// touch records that the session has just shown some activity
+func (session *session) touch() {
+	if session == nil {
+		return
+	}
+	atomic.StoreInt64(&session.lastSeen, time.Now().UnixNano())
+}
+
+// idle returns how long the session has been quiet
+func (session *session) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&session.lastSeen)))
+}
+
+// extendDeadline pushes forward the read deadline of the conn so a client
+// that stays quiet longer than IdleTimeout gets disconnected
+func (s *Server) extendDeadline(conn net.Conn) {
+	if s.IdleTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+	}
+}
+
+// full tells if the server reached MaxConnections
+func (s *Server) full() bool {
+	return s.MaxConnections > 0 &&
+		len(s.connections()) >= s.MaxConnections
+}
+
+// reject tells the client that there is no room for it and closes the conn
+func (s *Server) reject(conn net.Conn) {
+	if err := s.sendError(conn, &Base{}, &Error{
+		Message: "Too many connections",
+		Code:    -32000,
+	}); err != nil {
+		//log.Println("reject:sendError", err)
+	}
+	conn.Close()
+}
+
+// processHeartbeat answers the reserved methods rpc.ping and rpc.pong. It
+// returns false if the request is not one of them
+func (s *Server) processHeartbeat(request *Request, conn net.Conn) bool {
+	switch request.Method {
+	case "rpc.ping":
+		if err := s.send(conn, &Response{
+			Base:   request.Base,
+			Result: "pong",
+		}); err != nil {
+			//log.Println("processHeartbeat:send", err)
+		}
+		return true
+	case "rpc.pong":
+		return true
+	}
+	return false
+}
+
+// heartbeat sends a rpc.ping to every conn each HeartbeatInterval and
+// closes the conns that did not show any activity during HeartbeatTimeout.
+// A client answers the ping by sending a rpc.pong
+func (s *Server) heartbeat() {
+	timeout := s.HeartbeatTimeout
+	if timeout <= 0 {
+		timeout = 2 * s.HeartbeatInterval
+	}
+	ping, _ := json.Marshal(&Request{
+		Base: Base{
+			Method: "rpc.ping",
+		},
+	})
+
+	ticker := time.NewTicker(s.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		encoded := make(map[string][]byte)
+		for _, conn := range s.connections() {
+			session := s.session(conn)
+			if session == nil {
+				continue
+			}
+			if session.idle() > timeout {
+				conn.Close()
+				continue
+			}
+			payload, err := s.transcode(ping, s.encoding(conn), encoded)
+			if err != nil {
+				//log.Println("heartbeat:transcode", err)
+				continue
+			}
+			if err := s.write(conn, payload); err != nil {
+				//log.Println("heartbeat:write", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Heartbeat_MaxConnections_Rejects__OK(t *testing.T) {
+	server := &Server{Address: address, MaxConnections: 1}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn1, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	conn2, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := `{"ID":"","Method":"","Context":null,"Result":null,"Error":{"Code":-32000,"Message":"Too many connections","Data":null}}`
+	actual := receiveString(&conn2)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	conn2.Close()
+	conn1.Close()
+	server.Close()
+}
+
+func Test_Heartbeat_Ping_Pong__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.ping"
+
+	expected := `{"ID":"ID","Method":"rpc.ping","Context":null,"Result":"pong","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
+
+func Test_Heartbeat_IdleTimeout_Disconnects__OK(t *testing.T) {
+	server := &Server{Address: address, IdleTimeout: 100 * time.Millisecond}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Expecting the conn to be closed")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(server.connections()) != 0 {
+		t.Error("Expecting the conn to be unplugged")
+	}
+	server.Close()
+}
+
+func Test_Heartbeat_Evicts_Unresponsive__OK(t *testing.T) {
+	server := &Server{Address: address,
+		HeartbeatInterval: 50 * time.Millisecond}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := `{"ID":"","Method":"rpc.ping","Context":null,"Params":null}`
+	actual := receiveString(&conn)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		if _, err := conn.Read(make([]byte, 512)); err != nil {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(server.connections()) != 0 {
+		t.Error("Expecting the conn to be evicted")
+	}
+	server.Close()
+}
+
+func Test_Heartbeat_WriteTimeout_Drops_Stalled__OK(t *testing.T) {
+	server := &Server{Address: address, WriteTimeout: 100 * time.Millisecond}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	stalled, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+	defer stalled.Close()
+	time.Sleep(50 * time.Millisecond)
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+	go io.Copy(io.Discard, conn)
+	time.Sleep(50 * time.Millisecond)
+
+	msg := []byte(`{"Result":"` + strings.Repeat("x", 1<<20) + `"}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.connections()) == 2 && time.Now().Before(deadline) {
+		server.Broadcast(msg)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(server.connections()) != 1 {
+		t.Error("Expecting the stalled conn to be dropped")
+	}
+	conn.Close()
+	server.Close()
+}
+
+func Test_Heartbeat_Ping_Msgpack__OK(t *testing.T) {
+	server, conn, err := startGivenServerAndClient(t, &Server{
+		Address:           address,
+		HeartbeatInterval: 100 * time.Millisecond,
+		HeartbeatTimeout:  time.Second,
+	})
+	if err != nil {
+		return
+	}
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.codec"
+	request.Params = "msgpack"
+	expected := `{"ID":"ID","Method":"rpc.codec","Context":null,"Result":"msgpack","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	codec := MsgpackCodec{}
+	framer := ContentLengthFramer{}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	raw, err := framer.ReadFrame(bufio.NewReader(conn), 1<<10)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+	var ping Request
+	if err := codec.Unmarshal(raw, &ping); err != nil {
+		t.Error(err)
+	}
+	assertExpectedVsActualAndClose(t, "rpc.ping", ping.Method, server)
+	conn.Close()
+}
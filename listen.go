@@ -0,0 +1,143 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenSystemdFdsStart is the first file descriptor passed by systemd
+const listenSystemdFdsStart = 3
+
+var (
+	errNoSystemdListeners = errors.New("No listeners passed by systemd")
+	errServerNotStarted   = errors.New("Server not started")
+)
+
+// ListenerOptions configures the conns accepted by a listener. Contexts
+// lists the contexts reachable from the listener, nil means all of them.
+// Framer overrides the Framer of the server
+type ListenerOptions struct {
+	Contexts []string
+	Framer   Framer
+}
+
+// AddListener makes a started server accept conns from one more listener.
+// All the listeners share the registers and the conns that receive the
+// broadcasts. Close closes the listener too. It fails if the server was not
+// started yet
+func (s *Server) AddListener(
+	listen net.Listener, options ListenerOptions) error {
+	if s.plugBlocker == nil {
+		return errServerNotStarted
+	}
+	s.plugBlocker.Lock()
+	s.listens = append(s.listens, listen)
+	s.plugBlocker.Unlock()
+	go s.startListen(listen, &options)
+	return nil
+}
+
+// listeners returns a copy of the array of listeners
+func (s *Server) listeners() []net.Listener {
+	s.plugBlocker.Lock()
+	defer s.plugBlocker.Unlock()
+	listens := make([]net.Listener, len(s.listens))
+	copy(listens, s.listens)
+	return listens
+}
+
+// reachable tells if the given context can be reached from the listener
+// that accepted the conn. A listener that allows a context reaches its
+// children too. Conns that are not plugged reach every context
+func (s *Server) reachable(conn net.Conn, context string) bool {
+	session := s.session(conn)
+	if session == nil || session.options.Contexts == nil {
+		return true
+	}
+	for level, ok := context, true; ok; level, ok = parentContext(level) {
+		for _, allowed := range session.options.Contexts {
+			if allowed == level {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listenAddress opens the listener described by Network and Address. The
+// network tcp is the default, unix listens on the socket file Address and
+// applies SocketMode to it, and systemd takes the first listener inherited
+// through socket activation, ignoring Address
+func (s *Server) listenAddress() (net.Listener, error) {
+	switch s.Network {
+	case "", "tcp", "tcp4", "tcp6":
+		network := s.Network
+		if network == "" {
+			network = "tcp"
+		}
+		config := &net.ListenConfig{KeepAlive: s.KeepAlive}
+		return config.Listen(context.Background(), network, s.Address)
+	case "unix":
+		listen, err := net.Listen("unix", s.Address)
+		if err != nil {
+			return nil, err
+		}
+		if s.SocketMode != 0 {
+			if err := os.Chmod(s.Address, s.SocketMode); err != nil {
+				listen.Close()
+				return nil, err
+			}
+		}
+		return listen, nil
+	case "systemd":
+		listeners, err := SystemdListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(listeners) == 0 {
+			return nil, errNoSystemdListeners
+		}
+		for _, extra := range listeners[1:] {
+			extra.Close()
+		}
+		return listeners[0], nil
+	}
+	return nil, fmt.Errorf("Unknown network %s", s.Network)
+}
+
+// SystemdListeners returns the listeners that systemd passed to the process
+// through socket activation, following sd_listen_fds(3). The environment
+// variables are unset so child processes do not inherit them
+func SystemdListeners() ([]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds <= 0 {
+		return nil, nil
+	}
+
+	listeners := make([]net.Listener, 0, fds)
+	for fd := listenSystemdFdsStart; fd < listenSystemdFdsStart+fds; fd++ {
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		listen, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listen)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,189 @@
+package jsonrpc
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Listen_Unix_Socket_With_Mode__OK(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arca.sock")
+	server := &Server{Network: "unix", Address: path, SocketMode: 0600}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("expect 0600, actual %v", info.Mode().Perm())
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.ping"
+	expected := `{"ID":"ID","Method":"rpc.ping","Context":null,"Result":"pong","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
+
+func Test_Listen_Serve_Given_Listener__OK(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	server := &Server{}
+	if err := server.Serve(listen); err != nil {
+		t.Error(err)
+		listen.Close()
+		return
+	}
+
+	conn, err := net.Dial("tcp", listen.Addr().String())
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.ping"
+	expected := `{"ID":"ID","Method":"rpc.ping","Context":null,"Result":"pong","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
+
+func Test_Listen_Systemd_Without_Activation__Fail(t *testing.T) {
+	os.Unsetenv("LISTEN_PID")
+	server := &Server{Network: "systemd"}
+	if err := server.Start(); err != errNoSystemdListeners {
+		t.Errorf("expect %v, actual %v", errNoSystemdListeners, err)
+	}
+}
+
+func Test_Listen_AddListener_Restricts_Contexts__OK(t *testing.T) {
+	server, public, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	listen, err := net.Listen("unix", path)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+	if err := server.AddListener(
+		listen, ListenerOptions{Contexts: []string{"Admin"}}); err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+	admin, err := net.Dial("unix", path)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+
+	pung :=
+		func(request *Request) (result interface{}, err error) {
+			result = "Pung"
+			return
+		}
+	server.RegisterSource("Pung", "Global", pung)
+	server.RegisterSource("Pung", "Admin", pung)
+	server.RegisterSource("Pung", "Administration", pung)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Pung"
+	request.Context = "Global"
+	expected := `{"ID":"ID","Method":"Pung","Context":"Global","Result":null,"Error":{"Code":-32601,"Message":"Method not found","Data":{"ID":"ID","Method":"Pung"}}}`
+	actual := sendJSONAndReceive(&admin, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	expected = `{"ID":"ID","Method":"Pung","Context":"Global","Result":"Pung","Error":null}`
+	actual = sendJSONAndReceive(&public, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	request.Context = "Admin"
+	expected = `{"ID":"ID","Method":"Pung","Context":"Admin","Result":"Pung","Error":null}`
+	actual = sendJSONAndReceive(&admin, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	request.Context = "Admin/users"
+	expected = `{"ID":"ID","Method":"Pung","Context":"Admin/users","Result":"Pung","Error":null}`
+	actual = sendJSONAndReceive(&admin, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	request.Context = "Administration"
+	expected = `{"ID":"ID","Method":"Pung","Context":"Administration","Result":null,"Error":{"Code":-32601,"Message":"Method not found","Data":{"ID":"ID","Method":"Pung"}}}`
+	actual = sendJSONAndReceive(&admin, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	broadcast := `{"ID":"ID","Method":"Ping","Context":"Global","Result":"Pong","Error":null}`
+	server.Broadcast([]byte(broadcast))
+	assertExpectedVsActualAndClose(t, broadcast, receiveString(&public), nil)
+	assertExpectedVsActualAndClose(t, broadcast, receiveString(&admin), server)
+
+	if _, err := net.Dial("unix", path); err == nil {
+		t.Error("Expecting the added listener to be closed")
+	}
+}
+
+func Test_Listen_AddListener_Before_Start__Fail(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer listen.Close()
+	server := &Server{}
+	if err := server.AddListener(listen, ListenerOptions{}); err != errServerNotStarted {
+		t.Errorf("expect %v, actual %v", errServerNotStarted, err)
+	}
+}
+
+func Test_Listen_Primary_Listener_Restricts_Contexts__OK(t *testing.T) {
+	server := &Server{Address: address,
+		ListenerOptions: ListenerOptions{Contexts: []string{"Admin"}}}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+
+	pung :=
+		func(request *Request) (result interface{}, err error) {
+			result = "Pung"
+			return
+		}
+	server.RegisterSource("Pung", "Global", pung)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Pung"
+	request.Context = "Global"
+	expected := `{"ID":"ID","Method":"Pung","Context":"Global","Result":null,"Error":{"Code":-32601,"Message":"Method not found","Data":{"ID":"ID","Method":"Pung"}}}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
@@ -0,0 +1,251 @@
+package jsonrpc
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the handler latency
+// histogram. They follow the default buckets of the Prometheus clients
+var latencyBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// requestKey identifies a series of the requests counter
+type requestKey struct {
+	source  string
+	context string
+	method  string
+	code    int
+}
+
+// handlerKey identifies a series of the handler latency histogram
+type handlerKey struct {
+	source  string
+	context string
+	method  string
+}
+
+// histogram keeps the per-bucket, non-cumulative, counts of a latency histogram
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// metrics collects the counters of the server. All the methods are safe for
+// concurrent use
+type metrics struct {
+	blocker           *sync.Mutex
+	requests          map[requestKey]uint64
+	latency           map[handlerKey]*histogram
+	acceptedConns     uint64
+	broadcasts        uint64
+	broadcastBytes    uint64
+	broadcastFailures uint64
+	storeFailures     uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		blocker:  &sync.Mutex{},
+		requests: make(map[requestKey]uint64),
+		latency:  make(map[handlerKey]*histogram),
+	}
+}
+
+// unknownLabel replaces the context and method of the requests that did not
+// match a registered handler, so clients cannot create unbounded series
+const unknownLabel = "unknown"
+
+// requestLabels returns the context and method a request is counted under,
+// which are the ones its handler was registered with, patterns included.
+// Requests that do not reach a registered handler, even if NotFound handles
+// them, share unknownLabel
+func (s *Server) requestLabels(
+	source, context, method string) (string, string) {
+	r := s.registry()
+	resolve := r.resolveSource
+	if source == "Target" {
+		resolve = r.resolveTarget
+	}
+	context, method, ok := resolve(context, method)
+	if !ok {
+		return unknownLabel, unknownLabel
+	}
+	return context, method
+}
+
+// observeRequest counts a processed request and the code it ended with. A
+// code equal to zero means that no error was returned
+func (m *metrics) observeRequest(source, context, method string, code int) {
+	m.blocker.Lock()
+	defer m.blocker.Unlock()
+	m.requests[requestKey{source, context, method, code}]++
+}
+
+// observeHandler records how long a handler took to execute
+func (m *metrics) observeHandler(
+	source, context, method string, elapsed time.Duration) {
+	m.blocker.Lock()
+	defer m.blocker.Unlock()
+	key := handlerKey{source, context, method}
+	h := m.latency[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latency[key] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// observeAccept counts an accepted connection
+func (m *metrics) observeAccept() {
+	m.blocker.Lock()
+	defer m.blocker.Unlock()
+	m.acceptedConns++
+}
+
+// observeBroadcast counts a broadcasted message, the bytes written and the
+// connections that failed to receive it
+func (m *metrics) observeBroadcast(bytes, failures int) {
+	m.blocker.Lock()
+	defer m.blocker.Unlock()
+	m.broadcasts++
+	m.broadcastBytes += uint64(bytes)
+	m.broadcastFailures += uint64(failures)
+}
+
+// observeStoreFailure counts a notification that did not reach the store
+func (m *metrics) observeStoreFailure() {
+	m.blocker.Lock()
+	defer m.blocker.Unlock()
+	m.storeFailures++
+}
+
+// writeTo dumps the metrics in the Prometheus text exposition format
+func (m *metrics) writeTo(w io.Writer, activeConns int) {
+	m.blocker.Lock()
+	defer m.blocker.Unlock()
+
+	fmt.Fprintln(w, "# HELP arca_jsonrpc_requests_total Processed requests by source, context, method and error code.")
+	fmt.Fprintln(w, "# TYPE arca_jsonrpc_requests_total counter")
+	requests := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requests = append(requests, key)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.source != b.source {
+			return a.source < b.source
+		}
+		if a.context != b.context {
+			return a.context < b.context
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	for _, key := range requests {
+		fmt.Fprintf(w, "arca_jsonrpc_requests_total{%s,code=\"%d\"} %d\n",
+			labels(key.source, key.context, key.method), key.code,
+			m.requests[key])
+	}
+
+	fmt.Fprintln(w, "# HELP arca_jsonrpc_handler_duration_seconds Time spent executing handlers.")
+	fmt.Fprintln(w, "# TYPE arca_jsonrpc_handler_duration_seconds histogram")
+	handlers := make([]handlerKey, 0, len(m.latency))
+	for key := range m.latency {
+		handlers = append(handlers, key)
+	}
+	sort.Slice(handlers, func(i, j int) bool {
+		a, b := handlers[i], handlers[j]
+		if a.source != b.source {
+			return a.source < b.source
+		}
+		if a.context != b.context {
+			return a.context < b.context
+		}
+		return a.method < b.method
+	})
+	for _, key := range handlers {
+		h := m.latency[key]
+		lbls := labels(key.source, key.context, key.method)
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w,
+				"arca_jsonrpc_handler_duration_seconds_bucket{%s,le=\"%g\"} %d\n",
+				lbls, bound, cumulative)
+		}
+		fmt.Fprintf(w,
+			"arca_jsonrpc_handler_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n",
+			lbls, h.count)
+		fmt.Fprintf(w, "arca_jsonrpc_handler_duration_seconds_sum{%s} %g\n",
+			lbls, h.sum)
+		fmt.Fprintf(w, "arca_jsonrpc_handler_duration_seconds_count{%s} %d\n",
+			lbls, h.count)
+	}
+
+	fmt.Fprintln(w, "# HELP arca_jsonrpc_connections_active Connections currently plugged.")
+	fmt.Fprintln(w, "# TYPE arca_jsonrpc_connections_active gauge")
+	fmt.Fprintf(w, "arca_jsonrpc_connections_active %d\n", activeConns)
+
+	fmt.Fprintln(w, "# HELP arca_jsonrpc_connections_accepted_total Connections accepted since start.")
+	fmt.Fprintln(w, "# TYPE arca_jsonrpc_connections_accepted_total counter")
+	fmt.Fprintf(w, "arca_jsonrpc_connections_accepted_total %d\n", m.acceptedConns)
+
+	fmt.Fprintln(w, "# HELP arca_jsonrpc_broadcasts_total Broadcasted messages.")
+	fmt.Fprintln(w, "# TYPE arca_jsonrpc_broadcasts_total counter")
+	fmt.Fprintf(w, "arca_jsonrpc_broadcasts_total %d\n", m.broadcasts)
+
+	fmt.Fprintln(w, "# HELP arca_jsonrpc_broadcast_bytes_total Bytes of the broadcasted messages, before framing.")
+	fmt.Fprintln(w, "# TYPE arca_jsonrpc_broadcast_bytes_total counter")
+	fmt.Fprintf(w, "arca_jsonrpc_broadcast_bytes_total %d\n", m.broadcastBytes)
+
+	fmt.Fprintln(w, "# HELP arca_jsonrpc_broadcast_failures_total Broadcast writes that failed.")
+	fmt.Fprintln(w, "# TYPE arca_jsonrpc_broadcast_failures_total counter")
+	fmt.Fprintf(w, "arca_jsonrpc_broadcast_failures_total %d\n", m.broadcastFailures)
+
+	fmt.Fprintln(w, "# HELP arca_jsonrpc_store_failures_total Notifications that did not reach the NotificationStore.")
+	fmt.Fprintln(w, "# TYPE arca_jsonrpc_store_failures_total counter")
+	fmt.Fprintf(w, "arca_jsonrpc_store_failures_total %d\n", m.storeFailures)
+}
+
+// labels formats the common labels of the request and handler series
+func labels(source, context, method string) string {
+	return fmt.Sprintf("source=\"%s\",context=\"%s\",method=\"%s\"",
+		escapeLabel(source), escapeLabel(context), escapeLabel(method))
+}
+
+// escapeLabel escapes a label value as the text exposition format expects
+func escapeLabel(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// WriteMetrics dumps the metrics of the server in the Prometheus text format
+func (s *Server) WriteMetrics(w io.Writer) {
+	s.metrics.writeTo(w, len(s.connections()))
+}
+
+// MetricsHandler returns an http.Handler that exposes the metrics of the
+// server. It is meant to be mounted at /metrics
+func (s *Server) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		s.WriteMetrics(w)
+	})
+}
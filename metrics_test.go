@@ -0,0 +1,126 @@
+package jsonrpc
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Metrics_Request_Counted_And_Exposed__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	pung :=
+		func(request *Request) (result interface{}, err error) {
+			var pong interface{} = "Pung"
+			result = &pong
+			return
+		}
+
+	server.RegisterSource("Pung", "Global", pung)
+	request := Request{}
+	request.ID = "ID"
+	request.Context = "Global"
+
+	request.Method = "Pung"
+	sendJSONAndReceive(&conn, &request)
+	request.Method = "Unknown"
+	sendJSONAndReceive(&conn, &request)
+	request.Method = "Other"
+	sendJSONAndReceive(&conn, &request)
+
+	recorder := httptest.NewRecorder()
+	server.MetricsHandler().ServeHTTP(
+		recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, expected := range []string{
+		`arca_jsonrpc_requests_total{source="Source",context="Global",method="Pung",code="0"} 1`,
+		`arca_jsonrpc_requests_total{source="Source",context="unknown",method="unknown",code="-32601"} 2`,
+		`arca_jsonrpc_handler_duration_seconds_count{source="Source",context="Global",method="Pung"} 1`,
+		`arca_jsonrpc_connections_active 1`,
+		`arca_jsonrpc_connections_accepted_total 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("\nexpect %s\nactual %s", expected, body)
+		}
+	}
+	server.Close()
+}
+
+func Test_Metrics_Patterns_And_NotFound_Bounded__OK(t *testing.T) {
+	server, conn, err := startGivenServerAndClient(t, &Server{
+		Address: address,
+		NotFound: func(request *Request) (result interface{}, err error) {
+			return "NotFound", nil
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	server.RegisterSource("Get*", "Global",
+		func(request *Request) (result interface{}, err error) {
+			return "Get", nil
+		})
+	request := Request{}
+	request.ID = "ID"
+	request.Context = "Global"
+	for _, method := range []string{"GetA", "GetB", "Random1", "Random2"} {
+		request.Method = method
+		sendJSONAndReceive(&conn, &request)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.MetricsHandler().ServeHTTP(
+		recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, expected := range []string{
+		`arca_jsonrpc_requests_total{source="Source",context="Global",method="Get*",code="0"} 2`,
+		`arca_jsonrpc_requests_total{source="Source",context="unknown",method="unknown",code="0"} 2`,
+		`arca_jsonrpc_handler_duration_seconds_count{source="Source",context="Global",method="Get*"} 2`,
+		`arca_jsonrpc_handler_duration_seconds_count{source="Source",context="unknown",method="unknown"} 2`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("\nexpect %s\nactual %s", expected, body)
+		}
+	}
+	for _, unexpected := range []string{"GetA", "Random1"} {
+		if strings.Contains(body, unexpected) {
+			t.Errorf("Unexpected series for %s\n%s", unexpected, body)
+		}
+	}
+	server.Close()
+}
+
+func Test_Metrics_Broadcast_Bytes__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	msg := `{"ID":"ID","Method":"Ping","Context":"Global","Result":"Pong","Error":null}`
+	server.Broadcast([]byte(msg))
+	actual := receiveString(&conn)
+	assertExpectedVsActualAndClose(t, msg, actual, nil)
+
+	recorder := httptest.NewRecorder()
+	server.WriteMetrics(recorder)
+	body := recorder.Body.String()
+
+	for _, expected := range []string{
+		`arca_jsonrpc_broadcasts_total 1`,
+		`arca_jsonrpc_broadcast_bytes_total 75`,
+		`arca_jsonrpc_broadcast_failures_total 0`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("\nexpect %s\nactual %s", expected, body)
+		}
+	}
+	server.Close()
+}
@@ -0,0 +1,874 @@
+package jsonrpc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxMsgpackDepth is how deep arrays and maps may be nested in a message
+const maxMsgpackDepth = 1000
+
+var (
+	errMsgpackTruncated = errors.New("msgpack: unexpected end of data")
+	errMsgpackTooDeep   = errors.New("msgpack: exceeded max depth")
+)
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	jsonNumberType      = reflect.TypeOf(json.Number(""))
+)
+
+// MsgpackCodec encodes the messages as MessagePack. Structs are encoded as
+// maps whose keys follow the same rules as encoding/json, so a message has
+// the same shape under both codecs
+type MsgpackCodec struct{}
+
+// Name returns msgpack
+func (MsgpackCodec) Name() string {
+	return "msgpack"
+}
+
+// Marshal encodes v as MessagePack
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	e := &msgpackEncoder{}
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Unmarshal decodes MessagePack data straight into v, following the rules
+// of json.Unmarshal. Binary data is kept as []byte and an interface{} gets
+// float64 numbers, as encoding/json gives
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: Unmarshal(non-pointer %T)", v)
+	}
+	d := &msgpackDecoder{buf: data}
+	if err := d.decodeInto(rv.Elem(), 0); err != nil {
+		return err
+	}
+	if d.pos != len(d.buf) {
+		return fmt.Errorf("msgpack: %d bytes left after the value",
+			len(d.buf)-d.pos)
+	}
+	return nil
+}
+
+type msgpackEncoder struct {
+	buf []byte
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) &&
+		(v.Kind() != reflect.Ptr || !v.IsNil()) {
+		return e.encodeJSONMarshaler(v.Interface().(json.Marshaler))
+	}
+	if v.Type() == jsonNumberType {
+		return e.encodeGeneric(json.Number(v.String()))
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, 0xca)
+		e.buf = binary.BigEndian.AppendUint32(
+			e.buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, 0xcb)
+		e.buf = binary.BigEndian.AppendUint64(
+			e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	case reflect.Map:
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// encodeJSONMarshaler encodes the generic value of the JSON given by m
+func (e *msgpackEncoder) encodeJSONMarshaler(m json.Marshaler) error {
+	raw, err := m.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var generic interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return err
+	}
+	return e.encodeGeneric(generic)
+}
+
+// encodeGeneric encodes the values produced by encoding/json
+func (e *msgpackEncoder) encodeGeneric(generic interface{}) error {
+	if number, ok := generic.(json.Number); ok {
+		if i, err := number.Int64(); err == nil {
+			e.encodeInt(i)
+			return nil
+		}
+		f, err := number.Float64()
+		if err != nil {
+			return err
+		}
+		return e.encode(reflect.ValueOf(f))
+	}
+	switch value := generic.(type) {
+	case []interface{}:
+		e.encodeLength(len(value), 0x90, 0xdc, 0xdd)
+		for _, item := range value {
+			if err := e.encodeGeneric(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		e.encodeLength(len(keys), 0x80, 0xde, 0xdf)
+		for _, key := range keys {
+			e.encodeString(key)
+			if err := e.encodeGeneric(value[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return e.encode(reflect.ValueOf(generic))
+}
+
+func (e *msgpackEncoder) encodeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.encodeUint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(int8(i)))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(int8(i)))
+	case i >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(int16(i)))
+	case i >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(int32(i)))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(i))
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(u))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, u)
+	}
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *msgpackEncoder) encodeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// encodeLength writes the header of an array or a map of n items
+func (e *msgpackEncoder) encodeLength(n int, fix, b16, b32 byte) {
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, b16)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, b32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) encodeArray(v reflect.Value) error {
+	e.encodeLength(v.Len(), 0x90, 0xdc, 0xdd)
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeMap(v reflect.Value) error {
+	if v.IsNil() {
+		e.buf = append(e.buf, 0xc0)
+		return nil
+	}
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	for _, key := range v.MapKeys() {
+		name := fmt.Sprint(key.Interface())
+		keys = append(keys, name)
+		values[name] = v.MapIndex(key)
+	}
+	sort.Strings(keys)
+	e.encodeLength(len(keys), 0x80, 0xde, 0xdf)
+	for _, key := range keys {
+		e.encodeString(key)
+		if err := e.encode(values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgpackField is an exported field of a struct and the key it is encoded as
+type msgpackField struct {
+	name      string
+	value     reflect.Value
+	omitEmpty bool
+}
+
+// structFields lists the fields of v as encoding/json would, promoting the
+// fields of the embedded structs
+func structFields(v reflect.Value) []msgpackField {
+	fields := make([]msgpackField, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && tag == "" {
+			embedded := v.Field(i)
+			if embedded.Kind() == reflect.Ptr {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, structFields(embedded)...)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.IndexByte(tag, ','); comma != -1 {
+			name, options = tag[:comma], tag[comma:]
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, msgpackField{
+			name:      name,
+			value:     v.Field(i),
+			omitEmpty: strings.Contains(options, ",omitempty"),
+		})
+	}
+	return fields
+}
+
+func (e *msgpackEncoder) encodeStruct(v reflect.Value) error {
+	fields := structFields(v)
+	encoded := fields[:0]
+	for _, field := range fields {
+		if field.omitEmpty && isEmptyValue(field.value) {
+			continue
+		}
+		encoded = append(encoded, field)
+	}
+	e.encodeLength(len(encoded), 0x80, 0xde, 0xdf)
+	for _, field := range encoded {
+		e.encodeString(field.name)
+		if err := e.encode(field.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEmptyValue follows the omitempty rules of encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+type msgpackDecoder struct {
+	buf []byte
+	pos int
+}
+
+// next consumes n bytes
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return nil, errMsgpackTruncated
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// length consumes a big-endian unsigned integer of size bytes
+func (d *msgpackDecoder) length(size int) (int, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	}
+	return int(binary.BigEndian.Uint32(b)), nil
+}
+
+// decode consumes the next value and returns it as a generic value.
+// Integers are kept as int64 or uint64
+func (d *msgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxMsgpackDepth {
+		return nil, errMsgpackTooDeep
+	}
+	head, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := head[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.decodeMap(int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.decodeArray(int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.decodeString(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xca:
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		size := 1 << (c - 0xcc)
+		b, err := d.next(size)
+		if err != nil {
+			return nil, err
+		}
+		var u uint64
+		for _, digit := range b {
+			u = u<<8 | uint64(digit)
+		}
+		return u, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		b, err := d.next(size)
+		if err != nil {
+			return nil, err
+		}
+		var u uint64
+		for _, digit := range b {
+			u = u<<8 | uint64(digit)
+		}
+		shift := uint(64 - 8*size)
+		return int64(u<<shift) >> shift, nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", c)
+}
+
+func (d *msgpackDecoder) decodeString(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) decodeArray(n, depth int) (interface{}, error) {
+	if n > len(d.buf)-d.pos {
+		return nil, errMsgpackTruncated
+	}
+	array := make([]interface{}, n)
+	for i := range array {
+		item, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		array[i] = item
+	}
+	return array, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n, depth int) (interface{}, error) {
+	if 2*n > len(d.buf)-d.pos {
+		return nil, errMsgpackTruncated
+	}
+	object := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		object[fmt.Sprint(key)] = value
+	}
+	return object, nil
+}
+
+// header returns the length of the array or map that comes next without
+// consuming its items. It returns false if the next value is not one
+func (d *msgpackDecoder) header(array bool) (int, bool, error) {
+	if d.pos >= len(d.buf) {
+		return 0, false, errMsgpackTruncated
+	}
+	c := d.buf[d.pos]
+	fix, b16 := byte(0x80), byte(0xde)
+	if array {
+		fix, b16 = 0x90, 0xdc
+	}
+	switch {
+	case c&0xf0 == fix:
+		d.pos++
+		return int(c & 0x0f), true, nil
+	case c == b16, c == b16+1:
+		d.pos++
+		n, err := d.length(2 << (c - b16))
+		if err != nil {
+			return 0, false, err
+		}
+		if n > len(d.buf)-d.pos {
+			return 0, false, errMsgpackTruncated
+		}
+		return n, true, nil
+	}
+	return 0, false, nil
+}
+
+// decodeInto consumes the next value and stores it in v
+func (d *msgpackDecoder) decodeInto(v reflect.Value, depth int) error {
+	if depth > maxMsgpackDepth {
+		return errMsgpackTooDeep
+	}
+	if d.pos >= len(d.buf) {
+		return errMsgpackTruncated
+	}
+	if d.buf[d.pos] == 0xc0 {
+		d.pos++
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() &&
+		v.Addr().Type().Implements(jsonUnmarshalerType) {
+		generic, err := d.decode(depth)
+		if err != nil {
+			return err
+		}
+		raw, err := json.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(raw)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeInto(v.Elem(), depth+1)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("msgpack: cannot decode into %s", v.Type())
+		}
+		generic, err := d.decode(depth)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(jsonGeneric(generic)))
+		return nil
+	case reflect.Struct:
+		return d.decodeStruct(v, depth)
+	case reflect.Map:
+		return d.decodeMapInto(v, depth)
+	case reflect.Slice, reflect.Array:
+		return d.decodeArrayInto(v, depth)
+	}
+
+	value, err := d.decode(depth)
+	if err != nil {
+		return err
+	}
+	return assignScalar(v, value)
+}
+
+func (d *msgpackDecoder) decodeStruct(v reflect.Value, depth int) error {
+	n, ok, err := d.header(false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("msgpack: cannot decode into %s", v.Type())
+	}
+	fields := structFields(v)
+	for i := 0; i < n; i++ {
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprint(key)
+		var field *msgpackField
+		for j := range fields {
+			if fields[j].name == name {
+				field = &fields[j]
+				break
+			}
+		}
+		if field == nil {
+			for j := range fields {
+				if strings.EqualFold(fields[j].name, name) {
+					field = &fields[j]
+					break
+				}
+			}
+		}
+		if field == nil {
+			if _, err := d.decode(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decodeInto(field.value, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeMapInto(v reflect.Value, depth int) error {
+	n, ok, err := d.header(false)
+	if err != nil {
+		return err
+	}
+	if !ok || v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("msgpack: cannot decode into %s", v.Type())
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), n))
+	}
+	for i := 0; i < n; i++ {
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return err
+		}
+		item := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decodeInto(item, depth+1); err != nil {
+			return err
+		}
+		v.SetMapIndex(
+			reflect.ValueOf(fmt.Sprint(key)).Convert(v.Type().Key()), item)
+	}
+	return nil
+}
+
+func (d *msgpackDecoder) decodeArrayInto(v reflect.Value, depth int) error {
+	n, ok, err := d.header(true)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("msgpack: cannot decode into %s", v.Type())
+		}
+		value, err := d.decode(depth)
+		if err != nil {
+			return err
+		}
+		var b []byte
+		switch value := value.(type) {
+		case []byte:
+			b = value
+		case string:
+			b = []byte(value)
+		default:
+			return fmt.Errorf("msgpack: cannot decode %T into %s",
+				value, v.Type())
+		}
+		if v.Kind() == reflect.Slice {
+			v.SetBytes(b)
+			return nil
+		}
+		reflect.Copy(v, reflect.ValueOf(b))
+		return nil
+	}
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+	}
+	for i := 0; i < n; i++ {
+		if i >= v.Len() {
+			if _, err := d.decode(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decodeInto(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	for i := n; i < v.Len(); i++ {
+		v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+	}
+	return nil
+}
+
+// assignScalar stores the generic value in v, checking that it fits
+func assignScalar(v reflect.Value, value interface{}) error {
+	mismatch := fmt.Errorf("msgpack: cannot decode %T into %s",
+		value, v.Type())
+	switch v.Kind() {
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return mismatch
+		}
+		v.SetBool(b)
+	case reflect.String:
+		switch value := value.(type) {
+		case string:
+			v.SetString(value)
+		case []byte:
+			v.SetString(string(value))
+		default:
+			return mismatch
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		var i int64
+		switch value := value.(type) {
+		case int64:
+			i = value
+		case uint64:
+			if value > math.MaxInt64 {
+				return mismatch
+			}
+			i = int64(value)
+		case float64:
+			if value != math.Trunc(value) {
+				return mismatch
+			}
+			i = int64(value)
+		default:
+			return mismatch
+		}
+		if v.OverflowInt(i) {
+			return mismatch
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch value := value.(type) {
+		case uint64:
+			u = value
+		case int64:
+			if value < 0 {
+				return mismatch
+			}
+			u = uint64(value)
+		case float64:
+			if value < 0 || value != math.Trunc(value) {
+				return mismatch
+			}
+			u = uint64(value)
+		default:
+			return mismatch
+		}
+		if v.OverflowUint(u) {
+			return mismatch
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		switch value := value.(type) {
+		case float64:
+			v.SetFloat(value)
+		case int64:
+			v.SetFloat(float64(value))
+		case uint64:
+			v.SetFloat(float64(value))
+		default:
+			return mismatch
+		}
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+// jsonGeneric turns the integers of a generic value into float64, as
+// encoding/json does when it decodes into an interface{}
+func jsonGeneric(generic interface{}) interface{} {
+	switch value := generic.(type) {
+	case int64:
+		return float64(value)
+	case uint64:
+		return float64(value)
+	case []interface{}:
+		for i, item := range value {
+			value[i] = jsonGeneric(item)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = jsonGeneric(item)
+		}
+	}
+	return generic
+}
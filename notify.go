@@ -0,0 +1,41 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"net"
+)
+
+// Notify sends to all the active connections a notification with the given
+// method and context whose Result is payload. The notification has no ID,
+// so clients can tell it apart from the answers to their requests
+func (s *Server) Notify(
+	method string, context interface{}, payload interface{}) error {
+	return s.NotifyExcept("", method, context, payload)
+}
+
+// NotifyExcept works as Notify but leaves out the connection with the given
+// ID, usually request.ConnID(), so the client that made a change does not
+// receive its own echo
+func (s *Server) NotifyExcept(connID string,
+	method string, context interface{}, payload interface{}) error {
+	msg, err := json.Marshal(&Response{
+		Base: Base{
+			Method:  method,
+			Context: context,
+		},
+		Result: payload,
+	})
+	if err != nil {
+		return err
+	}
+	conns := make([]net.Conn, 0)
+	for _, conn := range s.connections() {
+		session := s.session(conn)
+		if connID != "" && session != nil && session.id == connID {
+			continue
+		}
+		conns = append(conns, conn)
+	}
+	s.publish(msg, conns)
+	return nil
+}
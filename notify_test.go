@@ -0,0 +1,49 @@
+package jsonrpc
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Notify_Except_Sender__OK(t *testing.T) {
+	server, conn1, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+	conn2, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+	}
+
+	server.RegisterSource("Update", "Project",
+		func(request *Request) (result interface{}, err error) {
+			err = server.NotifyExcept(request.ConnID(),
+				"Updated", "Project", map[string]interface{}{"Name": "Arca"})
+			result = "OK"
+			return
+		})
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Update"
+	request.Context = "Project"
+	expected := `{"ID":"ID","Method":"Update","Context":"Project","Result":"OK","Error":null}`
+	actual := sendJSONAndReceive(&conn1, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	conn2.SetReadDeadline(time.Now().Add(time.Second))
+	expected = `{"ID":"","Method":"Updated","Context":"Project","Result":{"Name":"Arca"},"Error":null}`
+	actual = receiveString(&conn2)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	if err := server.Notify("Updated", "Project", "All"); err != nil {
+		t.Error(err)
+	}
+	expected = `{"ID":"","Method":"Updated","Context":"Project","Result":"All","Error":null}`
+	actual = receiveString(&conn1)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	conn2.Close()
+	server.Close()
+}
@@ -15,8 +15,14 @@ func (s *Server) ProcessNotification(
 		Context: request.Context,
 	}
 
+	code := 0
 	ctx, err := getFieldFromContext("Target", request.Context)
+	defer func() {
+		ctx, method := s.requestLabels("Target", ctx, request.Method)
+		s.metrics.observeRequest("Target", ctx, method, code)
+	}()
 	if err != nil {
+		code = -32603
 		s.BroadcastError(base, &Error{
 			Message: "Internal error",
 			Code:    -32603,
@@ -26,10 +32,12 @@ func (s *Server) ProcessNotification(
 				"ID":     request.ID,
 			},
 		})
+		return
 	}
 
 	response, err := s.findAndExecuteHandlerInTarget(ctx, request, base, db)
 	if err != nil {
+		code = -32603
 		s.BroadcastError(base, &Error{
 			Message: "Internal error",
 			Code:    -32603,
@@ -42,6 +50,7 @@ func (s *Server) ProcessNotification(
 	}
 	if response != nil {
 		if response.Error != nil {
+			code = -32603
 			s.BroadcastError(base, &Error{
 				Message: "Internal error",
 				Code:    -32603,
@@ -67,13 +76,32 @@ func (s *Server) ProcessRequest(
 		Context: request.Context,
 	}
 
+	defer request.wait()
+	session := s.session(conn)
+	cancel := s.withDeadline(request, session != nil)
+	defer cancel()
+	if session != nil {
+		request.connID = session.id
+		request.conn = conn
+		request.progress = s.newProgress(request, conn)
+		defer request.progress.stop()
+		session.track(request.ID, cancel)
+		defer session.untrack(request.ID)
+	}
+
 	src := "Source"
 	if conn == nil {
 		src = "Target"
 	}
+	code := 0
 	ctx, err := getFieldFromContext(src, request.Context)
+	defer func() {
+		ctx, method := s.requestLabels(src, ctx, request.Method)
+		s.metrics.observeRequest(src, ctx, method, code)
+	}()
 	if err != nil {
 		//log.Println("ProcessRequest:getFieldFromContext", err)
+		code = -32600
 		if err1 := s.sendError(conn, base, &Error{
 			Message: "Invalid Request",
 			Code:    -32600,
@@ -105,10 +133,50 @@ func (s *Server) ProcessRequest(
 		}
 	*/
 
+	if !s.reachable(conn, ctx) {
+		code = -32601
+		if err := s.sendError(conn, base, &Error{
+			Message: "Method not found",
+			Code:    -32601,
+			Data: map[string]string{
+				"Method": request.Method,
+				"ID":     request.ID,
+			},
+		}); err != nil {
+			//log.Println("ProcessRequest:reachable:sendError", err)
+		}
+		return
+	}
+
+	if retryAfter, abusive := s.allow(
+		conn, request.Method, ctx); retryAfter > 0 {
+		code = -32029
+		if err := s.sendError(conn, base, &Error{
+			Message: "Too many requests",
+			Code:    -32029,
+			Data: map[string]interface{}{
+				"Method":     request.Method,
+				"ID":         request.ID,
+				"RetryAfter": retryAfter.Milliseconds(),
+			},
+		}); err != nil {
+			//log.Println("ProcessRequest:allow:sendError", err)
+		}
+		if abusive {
+			conn.Close()
+		}
+		return
+	}
+
 	response, err := s.findAndExecuteHandlerInSource(ctx, request, base)
+	request.progress.stop()
+	if session != nil {
+		session.closeStream(request.ID)
+	}
 	if err != nil {
 		//log.Println("ProcessRequest:findAndExecuteHandlerInSource", err)
 		if err == errMethodNotMatch {
+			code = -32601
 			if err := s.sendError(conn, base, &Error{
 				Message: "Method not found",
 				Code:    -32601,
@@ -119,7 +187,33 @@ func (s *Server) ProcessRequest(
 			}); err != nil {
 				//log.Println("ProcessRequest:findAndExecuteHandlerInSource:errMethodNotMatch:sendError", err)
 			}
+		} else if err == errRequestCancelled {
+			code = -32800
+			if err := s.sendError(conn, base, &Error{
+				Message: "Request cancelled",
+				Code:    -32800,
+				Data: map[string]string{
+					"Method": request.Method,
+					"ID":     request.ID,
+				},
+			}); err != nil {
+				//log.Println("ProcessRequest:findAndExecuteHandlerInSource:errRequestCancelled:sendError", err)
+			}
+		} else if err == errRequestTimeout {
+			code = -32002
+			if err := s.sendError(conn, base, &Error{
+				Message: "Request timed out",
+				Code:    -32002,
+				Data: map[string]interface{}{
+					"Method":  request.Method,
+					"ID":      request.ID,
+					"Timeout": s.timeout(request).Milliseconds(),
+				},
+			}); err != nil {
+				//log.Println("ProcessRequest:findAndExecuteHandlerInSource:errRequestTimeout:sendError", err)
+			}
 		} else {
+			code = -32603
 			if err := s.sendError(conn, base, &Error{
 				Message: "Internal error",
 				Code:    -32603,
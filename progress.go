@@ -0,0 +1,121 @@
+package jsonrpc
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultProgressInterval is the ProgressInterval used when none is given
+const defaultProgressInterval = 100 * time.Millisecond
+
+// ProgressReport is the Result of a $/progress notification. ID is the ID
+// of the request and Token the ProgressToken given by the client, if any
+type ProgressReport struct {
+	ID         string
+	Token      string `json:",omitempty"`
+	Percentage float64
+	Message    string
+}
+
+// Progress sends $/progress notifications to the conn that made a request.
+// Reports closer than ProgressInterval are throttled: only the last of them
+// is sent once the interval passes or the handler returns, and none after
+// the request is answered
+type Progress struct {
+	blocker  sync.Mutex
+	server   *Server
+	conn     net.Conn
+	base     Base
+	interval time.Duration
+	last     time.Time
+	pending  *ProgressReport
+	timer    *time.Timer
+	stopped  bool
+}
+
+func (s *Server) newProgress(request *Request, conn net.Conn) *Progress {
+	interval := s.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	return &Progress{
+		server: s,
+		conn:   conn,
+		base: Base{
+			Method:  "$/progress",
+			Context: request.Context,
+		},
+		interval: interval,
+		pending: &ProgressReport{
+			ID:    request.ID,
+			Token: request.ProgressToken,
+		},
+	}
+}
+
+// Progress returns the progress reporter of the request. Reporting is a
+// no-op if the request did not come from a connection
+func (request *Request) Progress() *Progress {
+	return request.progress
+}
+
+// Report tells the client how much of the request is done, from 0 to 100,
+// and what is being done
+func (progress *Progress) Report(percentage float64, message string) {
+	if progress == nil {
+		return
+	}
+	progress.blocker.Lock()
+	defer progress.blocker.Unlock()
+	if progress.stopped {
+		return
+	}
+	report := *progress.pending
+	report.Percentage = percentage
+	report.Message = message
+	progress.pending = &report
+
+	wait := progress.interval - time.Since(progress.last)
+	if wait <= 0 {
+		progress.flush()
+		return
+	}
+	if progress.timer == nil {
+		progress.timer = time.AfterFunc(wait, func() {
+			progress.blocker.Lock()
+			defer progress.blocker.Unlock()
+			progress.timer = nil
+			if !progress.stopped {
+				progress.flush()
+			}
+		})
+	}
+}
+
+// flush sends the last report. The caller holds the blocker
+func (progress *Progress) flush() {
+	progress.last = time.Now()
+	if err := progress.server.send(progress.conn, &Response{
+		Base:   progress.base,
+		Result: *progress.pending,
+	}); err != nil {
+		//log.Println("Progress:send", err)
+	}
+}
+
+// stop sends the report that waits for the interval, if any, so the last
+// report comes before the response and nothing comes after it
+func (progress *Progress) stop() {
+	if progress == nil {
+		return
+	}
+	progress.blocker.Lock()
+	defer progress.blocker.Unlock()
+	progress.stopped = true
+	if progress.timer != nil {
+		progress.timer.Stop()
+		progress.timer = nil
+		progress.flush()
+	}
+}
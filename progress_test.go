@@ -0,0 +1,58 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Progress_Throttled_Reports__OK(t *testing.T) {
+	server := &Server{
+		Address:          address,
+		ProgressInterval: 100 * time.Millisecond,
+	}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	server.RegisterSource("Import", "Projects",
+		func(request *Request) (result interface{}, err error) {
+			for i := 1; i <= 10; i++ {
+				request.Progress().Report(float64(i*10), "Importing")
+			}
+			time.Sleep(150 * time.Millisecond)
+			request.Progress().Report(100, "Done")
+			result = "OK"
+			return
+		})
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	scanner := bufio.NewScanner(conn)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Import"
+	request.Context = "Projects"
+	request.ProgressToken = "Token"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected := `{"ID":"","Method":"$/progress","Context":"Projects","Result":{"ID":"ID","Token":"Token","Percentage":10,"Message":"Importing"},"Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+	scanner.Scan()
+	expected = `{"ID":"","Method":"$/progress","Context":"Projects","Result":{"ID":"ID","Token":"Token","Percentage":100,"Message":"Importing"},"Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+	scanner.Scan()
+	expected = `{"ID":"","Method":"$/progress","Context":"Projects","Result":{"ID":"ID","Token":"Token","Percentage":100,"Message":"Done"},"Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+	scanner.Scan()
+	expected = `{"ID":"ID","Method":"Import","Context":"Projects","Result":"OK","Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), server)
+	conn.Close()
+}
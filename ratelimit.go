@@ -0,0 +1,146 @@
+package jsonrpc
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// Rate describes a token bucket that holds up to Burst tokens and refills
+// PerSecond tokens every second. A zero Rate means no limit
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// RateLimits configures the token buckets that every connection gets.
+// Connection limits all the requests of a conn, Methods limits the requests
+// of a conn to a given method and Contexts to a given context. After
+// MaxViolations consecutive rejected requests the conn is closed, zero
+// disables the disconnection
+type RateLimits struct {
+	Connection    Rate
+	Methods       map[string]Rate
+	Contexts      map[string]Rate
+	MaxViolations int
+}
+
+// tokenBucket is the classic token bucket algorithm
+type tokenBucket struct {
+	rate   Rate
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate Rate, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		tokens: float64(rate.Burst),
+		last:   now,
+	}
+}
+
+// refill adds the tokens earned since the last call
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	b.tokens = math.Min(
+		float64(b.rate.Burst), b.tokens+elapsed*b.rate.PerSecond)
+}
+
+// wait tells how long to wait until one token is available
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	if b.rate.PerSecond <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration((1 - b.tokens) / b.rate.PerSecond * float64(time.Second))
+}
+
+// connLimiter holds the token buckets of a single conn
+type connLimiter struct {
+	blocker    *sync.Mutex
+	limits     *RateLimits
+	conn       *tokenBucket
+	methods    map[string]*tokenBucket
+	contexts   map[string]*tokenBucket
+	violations int
+}
+
+func newConnLimiter(limits *RateLimits) *connLimiter {
+	if limits == nil {
+		return nil
+	}
+	return &connLimiter{
+		blocker:  &sync.Mutex{},
+		limits:   limits,
+		methods:  make(map[string]*tokenBucket),
+		contexts: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes one token from every bucket that applies to the given method
+// and context. If any of them is empty nothing is taken and allow returns
+// how long to wait before retrying and whether the conn exceeded the
+// allowed violations
+func (l *connLimiter) allow(
+	method, context string) (retryAfter time.Duration, abusive bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.blocker.Lock()
+	defer l.blocker.Unlock()
+
+	now := time.Now()
+	buckets := make([]*tokenBucket, 0, 3)
+	if l.limits.Connection.Burst > 0 {
+		if l.conn == nil {
+			l.conn = newTokenBucket(l.limits.Connection, now)
+		}
+		buckets = append(buckets, l.conn)
+	}
+	if rate, ok := l.limits.Methods[method]; ok && rate.Burst > 0 {
+		if l.methods[method] == nil {
+			l.methods[method] = newTokenBucket(rate, now)
+		}
+		buckets = append(buckets, l.methods[method])
+	}
+	if rate, ok := l.limits.Contexts[context]; ok && rate.Burst > 0 {
+		if l.contexts[context] == nil {
+			l.contexts[context] = newTokenBucket(rate, now)
+		}
+		buckets = append(buckets, l.contexts[context])
+	}
+
+	for _, bucket := range buckets {
+		bucket.refill(now)
+		if wait := bucket.wait(); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	if retryAfter > 0 {
+		l.violations++
+		abusive = l.limits.MaxViolations > 0 &&
+			l.violations >= l.limits.MaxViolations
+		return
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	l.violations = 0
+	return
+}
+
+// allow applies the rate limits of the given conn. Conns that are not
+// plugged, like the ones of the notifications, are never limited
+func (s *Server) allow(
+	conn net.Conn, method, context string) (time.Duration, bool) {
+	session := s.session(conn)
+	if session == nil {
+		return 0, false
+	}
+	return session.limiter.allow(method, context)
+}
@@ -0,0 +1,69 @@
+package jsonrpc
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_RateLimits_Method_Exceeded_Then_Disconnect__OK(t *testing.T) {
+	server := &Server{Address: address, RateLimits: &RateLimits{
+		Methods:       map[string]Rate{"Pung": {PerSecond: 0.01, Burst: 1}},
+		MaxViolations: 2,
+	}}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+
+	pung :=
+		func(request *Request) (result interface{}, err error) {
+			result = "Pung"
+			return
+		}
+	server.RegisterSource("Pung", "Global", pung)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Pung"
+	request.Context = "Global"
+
+	expected := `{"ID":"ID","Method":"Pung","Context":"Global","Result":"Pung","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	prefix := `{"ID":"ID","Method":"Pung","Context":"Global","Result":null,"Error":{"Code":-32029,"Message":"Too many requests","Data":{"ID":"ID","Method":"Pung","RetryAfter":`
+	for i := 0; i < 2; i++ {
+		actual = sendJSONAndReceive(&conn, &request)
+		if !strings.HasPrefix(actual, prefix) {
+			t.Errorf("\nexpect %s\nactual %s", prefix, actual)
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Expecting the conn to be closed")
+	}
+	server.Close()
+}
+
+func Test_RateLimits_Token_Bucket_Refills__OK(t *testing.T) {
+	now := time.Now()
+	bucket := newTokenBucket(Rate{PerSecond: 10, Burst: 2}, now)
+	bucket.tokens = 0
+
+	if wait := bucket.wait(); wait != 100*time.Millisecond {
+		t.Errorf("expect 100ms, actual %v", wait)
+	}
+	bucket.refill(now.Add(time.Second))
+	if bucket.tokens != 2 {
+		t.Errorf("expect 2 tokens, actual %v", bucket.tokens)
+	}
+}
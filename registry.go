@@ -0,0 +1,301 @@
+package jsonrpc
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// registry is an immutable snapshot of the registered handlers. Writers
+// copy the parts they change and publish a new snapshot, so dispatch reads
+// it without locking
+type registry struct {
+	source         map[string]map[string]RemoteProcedure
+	target         map[string]map[string]DBRemoteProcedure
+	descriptions   map[string]MethodDescription
+	sourcePatterns *patterns
+	targetPatterns *patterns
+}
+
+func newRegistry() *registry {
+	r := &registry{
+		source:       make(map[string]map[string]RemoteProcedure),
+		target:       make(map[string]map[string]DBRemoteProcedure),
+		descriptions: make(map[string]MethodDescription),
+	}
+	r.index()
+	return r
+}
+
+// clone copies the top level maps of the registry. The maps of every
+// context are shared, so they must be copied before changing them
+func (r *registry) clone() *registry {
+	c := &registry{
+		source:       make(map[string]map[string]RemoteProcedure, len(r.source)),
+		target:       make(map[string]map[string]DBRemoteProcedure, len(r.target)),
+		descriptions: make(map[string]MethodDescription, len(r.descriptions)),
+	}
+	for context, rps := range r.source {
+		c.source[context] = rps
+	}
+	for context, rps := range r.target {
+		c.target[context] = rps
+	}
+	for key, description := range r.descriptions {
+		c.descriptions[key] = description
+	}
+	return c
+}
+
+// descriptionKey identifies the description of a method
+func descriptionKey(source, context, method string) string {
+	return source + "/" + context + "/" + method
+}
+
+// registry returns the current snapshot of the handlers
+func (s *Server) registry() *registry {
+	return s.registers.Load().(*registry)
+}
+
+// updateRegistry applies change to a copy of the registry and publishes it
+func (s *Server) updateRegistry(change func(r *registry)) {
+	s.registerBlocker.Lock()
+	defer s.registerBlocker.Unlock()
+	r := s.registry().clone()
+	change(r)
+	r.index()
+	s.registers.Store(r)
+}
+
+// UnregisterSource drops the handler registered with RegisterSource for the
+// given method and context. Requests being handled are not affected
+func (s *Server) UnregisterSource(method string, context string) {
+	s.updateRegistry(func(r *registry) {
+		rps := make(map[string]RemoteProcedure, len(r.source[context]))
+		for name, rp := range r.source[context] {
+			if name != method {
+				rps[name] = rp
+			}
+		}
+		if len(rps) == 0 {
+			delete(r.source, context)
+		} else {
+			r.source[context] = rps
+		}
+		delete(r.descriptions, descriptionKey("Source", context, method))
+	})
+}
+
+// UnregisterTarget drops the handler registered with RegisterTarget for the
+// given method and context
+func (s *Server) UnregisterTarget(method string, context string) {
+	s.updateRegistry(func(r *registry) {
+		rps := make(map[string]DBRemoteProcedure, len(r.target[context]))
+		for name, rp := range r.target[context] {
+			if name != method {
+				rps[name] = rp
+			}
+		}
+		if len(rps) == 0 {
+			delete(r.target, context)
+		} else {
+			r.target[context] = rps
+		}
+		delete(r.descriptions, descriptionKey("Target", context, method))
+	})
+}
+
+// ReplaceSource swaps at once every Source handler of the given context.
+// An empty rps removes the context. The descriptions of the methods that
+// are gone are dropped too
+func (s *Server) ReplaceSource(
+	context string, rps map[string]RemoteProcedure) {
+	s.updateRegistry(func(r *registry) {
+		for method := range r.source[context] {
+			if rps[method] == nil {
+				delete(r.descriptions, descriptionKey("Source", context, method))
+			}
+		}
+		if len(rps) == 0 {
+			delete(r.source, context)
+			return
+		}
+		copied := make(map[string]RemoteProcedure, len(rps))
+		for method, rp := range rps {
+			copied[method] = rp
+		}
+		r.source[context] = copied
+	})
+}
+
+// ReplaceTarget swaps at once every Target handler of the given context
+func (s *Server) ReplaceTarget(
+	context string, rps map[string]DBRemoteProcedure) {
+	s.updateRegistry(func(r *registry) {
+		for method := range r.target[context] {
+			if rps[method] == nil {
+				delete(r.descriptions, descriptionKey("Target", context, method))
+			}
+		}
+		if len(rps) == 0 {
+			delete(r.target, context)
+			return
+		}
+		copied := make(map[string]DBRemoteProcedure, len(rps))
+		for method, rp := range rps {
+			copied[method] = rp
+		}
+		r.target[context] = copied
+	})
+}
+
+// isPattern tells if a registered context or method contains wildcards
+func isPattern(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
+// sortPatterns sorts the patterns the most specific first. A pattern is
+// more specific than another if it has more characters that are not
+// wildcards, so "*" comes last and works as a catch-all
+func sortPatterns(patterns []string) {
+	literal := func(pattern string) int {
+		return len(pattern) - strings.Count(pattern, "*") -
+			strings.Count(pattern, "?")
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := literal(patterns[i]), literal(patterns[j])
+		if a != b {
+			return a > b
+		}
+		return patterns[i] < patterns[j]
+	})
+}
+
+// patterns keeps, sorted, the registered contexts with wildcards and the
+// methods with wildcards of every context
+type patterns struct {
+	contexts []string
+	methods  map[string][]string
+}
+
+// add registers the keys of a context that are patterns
+func (p *patterns) add(context string, methods []string) {
+	if isPattern(context) {
+		p.contexts = append(p.contexts, context)
+	}
+	for _, method := range methods {
+		if isPattern(method) {
+			p.methods[context] = append(p.methods[context], method)
+		}
+	}
+	sortPatterns(p.methods[context])
+}
+
+// index builds the patterns of the registry. It runs once per snapshot so
+// dispatch does not sort on every request
+func (r *registry) index() {
+	r.sourcePatterns = &patterns{methods: make(map[string][]string)}
+	for context, rps := range r.source {
+		methods := make([]string, 0, len(rps))
+		for method := range rps {
+			methods = append(methods, method)
+		}
+		r.sourcePatterns.add(context, methods)
+	}
+	sortPatterns(r.sourcePatterns.contexts)
+
+	r.targetPatterns = &patterns{methods: make(map[string][]string)}
+	for context, rps := range r.target {
+		methods := make([]string, 0, len(rps))
+		for method := range rps {
+			methods = append(methods, method)
+		}
+		r.targetPatterns.add(context, methods)
+	}
+	sortPatterns(r.targetPatterns.contexts)
+}
+
+// resolve returns the registered context and method that handle the given
+// ones. The context is looked up first and then its parents. At every level
+// the exact context goes before the patterns of contexts and, inside a
+// context, the exact method before the patterns of methods. found tells if
+// a context has the exact method
+func (p *patterns) resolve(context, method string,
+	found func(context, method string) bool) (string, string, bool) {
+	level := context
+	for {
+		if name, ok := p.resolveMethod(level, method, found); ok {
+			return level, name, true
+		}
+		for _, pattern := range p.contexts {
+			if ok, _ := path.Match(pattern, level); !ok {
+				continue
+			}
+			if name, ok := p.resolveMethod(pattern, method, found); ok {
+				return pattern, name, true
+			}
+		}
+		parent, ok := parentContext(level)
+		if !ok {
+			return "", "", false
+		}
+		level = parent
+	}
+}
+
+// resolveMethod returns the registered method of the given context that
+// handles method
+func (p *patterns) resolveMethod(context, method string,
+	found func(context, method string) bool) (string, bool) {
+	if found(context, method) {
+		return method, true
+	}
+	for _, pattern := range p.methods[context] {
+		if ok, _ := path.Match(pattern, method); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// findSource returns the Source handler of the given context and method,
+// following the rules of resolve
+func (r *registry) findSource(context, method string) RemoteProcedure {
+	context, method, ok := r.resolveSource(context, method)
+	if !ok {
+		return nil
+	}
+	return r.source[context][method]
+}
+
+// resolveSource returns the registered context and method of the Source
+// handler of the given context and method
+func (r *registry) resolveSource(
+	context, method string) (string, string, bool) {
+	return r.sourcePatterns.resolve(context, method,
+		func(context, method string) bool {
+			_, ok := r.source[context][method]
+			return ok
+		})
+}
+
+// findTarget returns the Target handler of the given context and method,
+// following the same rules as findSource
+func (r *registry) findTarget(context, method string) DBRemoteProcedure {
+	context, method, ok := r.resolveTarget(context, method)
+	if !ok {
+		return nil
+	}
+	return r.target[context][method]
+}
+
+// resolveTarget returns the registered context and method of the Target
+// handler of the given context and method
+func (r *registry) resolveTarget(
+	context, method string) (string, string, bool) {
+	return r.targetPatterns.resolve(context, method,
+		func(context, method string) bool {
+			_, ok := r.target[context][method]
+			return ok
+		})
+}
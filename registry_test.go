@@ -0,0 +1,189 @@
+package jsonrpc
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Registry_Unregister_And_Replace__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	answer := func(value string) RemoteProcedure {
+		return func(request *Request) (result interface{}, err error) {
+			result = value
+			return
+		}
+	}
+	server.RegisterSource("Ping", "Global", answer("Pong"))
+	server.RegisterSource("Pang", "Global", answer("Pung"))
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Ping"
+	request.Context = "Global"
+	expected := `{"ID":"ID","Method":"Ping","Context":"Global","Result":"Pong","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	server.UnregisterSource("Ping", "Global")
+	expected = `{"ID":"ID","Method":"Ping","Context":"Global","Result":null,"Error":{"Code":-32601,"Message":"Method not found","Data":{"ID":"ID","Method":"Ping"}}}`
+	actual = sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	server.ReplaceSource("Global", map[string]RemoteProcedure{
+		"Ping": answer("Pong v2"),
+	})
+	expected = `{"ID":"ID","Method":"Ping","Context":"Global","Result":"Pong v2","Error":null}`
+	actual = sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	request.Method = "Pang"
+	expected = `{"ID":"ID","Method":"Pang","Context":"Global","Result":null,"Error":{"Code":-32601,"Message":"Method not found","Data":{"ID":"ID","Method":"Pang"}}}`
+	actual = sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
+
+func Test_Registry_Register_While_Dispatching__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	pong :=
+		func(request *Request) (result interface{}, err error) {
+			result = "Pong"
+			return
+		}
+	server.RegisterSource("Ping", "Global", pong)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			method := fmt.Sprintf("Method%d", i)
+			server.RegisterSource(method, "Global", pong)
+			server.UnregisterSource(method, "Global")
+		}
+	}()
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Ping"
+	request.Context = "Global"
+	expected := `{"ID":"ID","Method":"Ping","Context":"Global","Result":"Pong","Error":null}`
+	for i := 0; i < 20; i++ {
+		actual := sendJSONAndReceive(&conn, &request)
+		assertExpectedVsActualAndClose(t, expected, actual, nil)
+	}
+	wg.Wait()
+	server.Close()
+}
+
+func Test_Registry_Wildcards_And_NotFound__OK(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+
+	answer := func(value string) RemoteProcedure {
+		return func(request *Request) (result interface{}, err error) {
+			result = value
+			return
+		}
+	}
+	server.RegisterSource("GetRows", "Projects", answer("exact"))
+	server.RegisterSource("Get*", "Projects", answer("Get*"))
+	server.RegisterSource("*", "Projects", answer("fallback"))
+	server.RegisterSource("Get*", "*", answer("any context"))
+
+	request := Request{}
+	request.ID = "ID"
+	request.Context = "Projects"
+	for method, expected := range map[string]string{
+		"GetRows":   "exact",
+		"GetTotals": "Get*",
+		"Delete":    "fallback",
+	} {
+		request.Method = method
+		actual := sendJSONAndReceive(&conn, &request)
+		assertExpectedVsActualAndClose(t,
+			`{"ID":"ID","Method":"`+method+`","Context":"Projects","Result":"`+
+				expected+`","Error":null}`, actual, nil)
+	}
+
+	request.Context = "Budgets"
+	request.Method = "GetRows"
+	expected := `{"ID":"ID","Method":"GetRows","Context":"Budgets","Result":"any context","Error":null}`
+	actual := sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	request.Method = "Delete"
+	expected = `{"ID":"ID","Method":"Delete","Context":"Budgets","Result":null,"Error":{"Code":-32601,"Message":"Method not found","Data":{"ID":"ID","Method":"Delete"}}}`
+	actual = sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, nil)
+
+	server.NotFound = answer("not found hook")
+	expected = `{"ID":"ID","Method":"Delete","Context":"Budgets","Result":"not found hook","Error":null}`
+	actual = sendJSONAndReceive(&conn, &request)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+}
+
+func Test_Registry_Exact_Match_Does_Not_Allocate__OK(t *testing.T) {
+	r := newRegistry()
+	r.source["Projects"] = map[string]RemoteProcedure{
+		"GetRows": func(request *Request) (result interface{}, err error) {
+			return
+		},
+		"Get*": func(request *Request) (result interface{}, err error) {
+			return
+		},
+	}
+	r.source["*"] = r.source["Projects"]
+	r.index()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if r.findSource("Projects/Rows", "GetRows") == nil {
+			t.Error("Expecting GetRows to be found")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expect 0 allocations, actual %v", allocs)
+	}
+}
+
+func Test_Registry_Wildcard_Target_Invalid_Context__Fail(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	called := false
+	server.RegisterTarget("*", "*",
+		func(db *sql.DB) RemoteProcedure {
+			return func(request *Request) (result interface{}, err error) {
+				called = true
+				return
+			}
+		})
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Insert"
+	request.Context = map[string]interface{}{"Source": "Global"}
+	server.ProcessNotification(&request, nil)
+
+	expected := `{"ID":"ID","Method":"Insert","Context":{"Source":"Global"},"Result":null,"Error":{"Code":-32603,"Message":"Internal error","Data":{"Error":"Incorrect context map[Source:Global]","ID":"ID","Method":"Insert"}}}`
+	actual := receiveString(&conn)
+	assertExpectedVsActualAndClose(t, expected, actual, server)
+	if called {
+		t.Error("Expecting the handler not to run")
+	}
+}
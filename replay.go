@@ -0,0 +1,246 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replayEntry is a broadcasted message kept for replay
+type replayEntry struct {
+	seq uint64
+	at  time.Time
+	msg []byte
+}
+
+// replay numbers the broadcasted messages. It keeps the last of them,
+// bounded by count and age, and queues all of them for the store if any
+type replay struct {
+	blocker *sync.Mutex
+	seq     uint64
+	size    int
+	age     time.Duration
+	entries []replayEntry
+	store   NotificationStore
+	pending chan *Notification
+	failed  func(notification *Notification, err error)
+}
+
+// newReplay returns nil if neither ReplaySize nor NotificationStore are
+// given. With a store the sequence continues from its last notification
+func (s *Server) newReplay() (*replay, error) {
+	if s.ReplaySize <= 0 && s.NotificationStore == nil {
+		return nil, nil
+	}
+	r := &replay{
+		blocker: &sync.Mutex{},
+		size:    s.ReplaySize,
+		age:     s.ReplayAge,
+		store:   s.NotificationStore,
+		failed:  s.storeFailed,
+	}
+	if r.store != nil {
+		last, err := r.store.Last()
+		if err != nil {
+			return nil, err
+		}
+		r.seq = last
+		r.pending = make(chan *Notification, maxQueuedNotifications)
+	}
+	return r, nil
+}
+
+// hasSeq tells if the JSON object already carries a field Seq
+func hasSeq(msg []byte) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return false
+	}
+	for name := range fields {
+		if strings.EqualFold(name, "Seq") {
+			return true
+		}
+	}
+	return false
+}
+
+// record numbers the given message with the next sequence, keeps it and
+// hands it to deliver. The sequence is added to the JSON object as the
+// field Seq. deliver runs while the sequence is held, so the messages are
+// delivered in the order of their sequence. Messages that are not JSON
+// objects or already carry a Seq are delivered as they are
+func (r *replay) record(msg []byte, deliver func(msg []byte)) {
+	if r == nil {
+		deliver(msg)
+		return
+	}
+	trimmed := bytes.TrimSpace(msg)
+	if len(trimmed) < 2 ||
+		trimmed[0] != '{' || trimmed[len(trimmed)-1] != '}' ||
+		hasSeq(trimmed) {
+		deliver(msg)
+		return
+	}
+
+	r.blocker.Lock()
+	defer r.blocker.Unlock()
+	r.seq++
+	body := trimmed[:len(trimmed)-1]
+	numbered := make([]byte, 0, len(body)+32)
+	numbered = append(numbered, body...)
+	if len(bytes.TrimSpace(body[1:])) > 0 {
+		numbered = append(numbered, ',')
+	}
+	numbered = append(numbered, `"Seq":`...)
+	numbered = strconv.AppendUint(numbered, r.seq, 10)
+	numbered = append(numbered, '}')
+
+	now := time.Now()
+	if r.store != nil {
+		notification := &Notification{
+			Seq:     r.seq,
+			Context: notificationContext(numbered),
+			Message: numbered,
+			At:      now,
+		}
+		select {
+		case r.pending <- notification:
+		default:
+			r.failed(notification, errNotificationStoreBehind)
+		}
+	}
+	if r.size > 0 {
+		r.expire(now)
+		if len(r.entries) == r.size {
+			r.entries = r.entries[1:]
+		}
+		r.entries = append(r.entries, replayEntry{
+			seq: r.seq,
+			at:  now,
+			msg: numbered,
+		})
+	}
+	deliver(numbered)
+}
+
+// expire drops the entries older than the age of the buffer
+func (r *replay) expire(now time.Time) {
+	if r.age <= 0 {
+		return
+	}
+	i := 0
+	for i < len(r.entries) && now.Sub(r.entries[i].at) > r.age {
+		i++
+	}
+	r.entries = r.entries[i:]
+}
+
+// since hands to deliver the messages whose sequence is greater than seq
+// and the current sequence, or false if some of them are not kept anymore.
+// As in record, deliver runs while the sequence is held, so no newer
+// message is delivered before them
+func (r *replay) since(seq uint64,
+	deliver func(msgs [][]byte, current uint64, ok bool)) {
+	r.blocker.Lock()
+	defer r.blocker.Unlock()
+	r.expire(time.Now())
+	if seq > r.seq {
+		deliver(nil, r.seq, false)
+		return
+	}
+	if seq == r.seq {
+		deliver(nil, r.seq, true)
+		return
+	}
+	if len(r.entries) == 0 || r.entries[0].seq > seq+1 {
+		deliver(nil, r.seq, false)
+		return
+	}
+	msgs := make([][]byte, 0, r.seq-seq)
+	for _, entry := range r.entries {
+		if entry.seq > seq {
+			msgs = append(msgs, entry.msg)
+		}
+	}
+	deliver(msgs, r.seq, true)
+}
+
+// processReplay answers the reserved method rpc.replay, whose Params is the
+// sequence of the last broadcast the client received. The answer gives the
+// current sequence and the count of messages that follow it, which are the
+// missed broadcasts. If they are not kept anymore the answer is the error
+// -32001 and the client must reload its data. It returns false if the
+// request is not rpc.replay
+func (s *Server) processReplay(request *Request, conn net.Conn) bool {
+	if request.Method != "rpc.replay" {
+		return false
+	}
+	var seq uint64
+	raw, err := json.Marshal(request.Params)
+	if err == nil {
+		err = json.Unmarshal(raw, &seq)
+	}
+	if err != nil || s.ReplaySize <= 0 {
+		message := "Replay is disabled"
+		if err != nil {
+			message = err.Error()
+		}
+		if err := s.sendError(conn, &request.Base, &Error{
+			Message: "Invalid params",
+			Code:    -32602,
+			Data: map[string]string{
+				"Method": request.Method,
+				"ID":     request.ID,
+				"Error":  message,
+			},
+		}); err != nil {
+			//log.Println("processReplay:sendError", err)
+		}
+		return true
+	}
+
+	// The answer and the missed messages go through the queue of the conn
+	// while the sequence is held, so the broadcasts that follow them are
+	// not delivered before
+	s.replays.since(seq, func(msgs [][]byte, current uint64, ok bool) {
+		var response *Response
+		if ok {
+			response = &Response{
+				Base: request.Base,
+				Result: map[string]interface{}{
+					"Seq":   current,
+					"Count": len(msgs),
+				},
+			}
+		} else {
+			response = &Response{
+				Base: request.Base,
+				Error: &Error{
+					Message: "Resync required",
+					Code:    -32001,
+					Data: map[string]interface{}{
+						"Method": request.Method,
+						"ID":     request.ID,
+						"Seq":    current,
+					},
+				},
+			}
+		}
+		msg, err := s.marshal(conn, response)
+		if err != nil {
+			//log.Println("processReplay:marshal", err)
+			return
+		}
+		if !s.enqueue(conn, msg) {
+			return
+		}
+		for _, msg := range msgs {
+			s.fanOut(msg, []net.Conn{conn})
+		}
+	})
+	return true
+}
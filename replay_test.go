@@ -0,0 +1,106 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Replay_Since_Sequence__OK(t *testing.T) {
+	server := &Server{Address: address, ReplaySize: 2}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	scanner := bufio.NewScanner(conn)
+	receive := func() string {
+		scanner.Scan()
+		return scanner.Text()
+	}
+
+	server.Notify("Updated", "Project", "1")
+	server.Notify("Updated", "Project", "2")
+	server.Broadcast([]byte(`{"Method":"Updated","Result":"3"}`))
+	expected := `{"ID":"","Method":"Updated","Context":"Project","Result":"1","Error":null,"Seq":1}`
+	assertExpectedVsActualAndClose(t, expected, receive(), nil)
+	expected = `{"ID":"","Method":"Updated","Context":"Project","Result":"2","Error":null,"Seq":2}`
+	assertExpectedVsActualAndClose(t, expected, receive(), nil)
+	expected = `{"Method":"Updated","Result":"3","Seq":3}`
+	assertExpectedVsActualAndClose(t, expected, receive(), nil)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.replay"
+	request.Params = 1
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+	expected = `{"ID":"ID","Method":"rpc.replay","Context":null,"Result":{"Count":2,"Seq":3},"Error":null}`
+	assertExpectedVsActualAndClose(t, expected, receive(), nil)
+	expected = `{"ID":"","Method":"Updated","Context":"Project","Result":"2","Error":null,"Seq":2}`
+	assertExpectedVsActualAndClose(t, expected, receive(), nil)
+	expected = `{"Method":"Updated","Result":"3","Seq":3}`
+	assertExpectedVsActualAndClose(t, expected, receive(), nil)
+
+	request.Params = 0
+	msg, _ = json.Marshal(&request)
+	send(&conn, msg)
+	expected = `{"ID":"ID","Method":"rpc.replay","Context":null,"Result":null,"Error":{"Code":-32001,"Message":"Resync required","Data":{"ID":"ID","Method":"rpc.replay","Seq":3}}}`
+	assertExpectedVsActualAndClose(t, expected, receive(), nil)
+
+	conn.Close()
+	server.Close()
+}
+
+func Test_Replay_Concurrent_Broadcasts_In_Order__OK(t *testing.T) {
+	server := &Server{Address: address, ReplaySize: 1000}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				server.Notify("Updated", "Project", j)
+			}
+		}()
+	}
+	server.Broadcast([]byte(`{"Method":"Updated","Seq":7}`))
+
+	scanner := bufio.NewScanner(conn)
+	last := uint64(0)
+	for i := 0; i < 201; i++ {
+		scanner.Scan()
+		var response Response
+		json.Unmarshal(scanner.Bytes(), &response)
+		if response.Seq == 7 && response.Result == nil {
+			continue
+		}
+		if response.Seq != last+1 {
+			t.Errorf("expect Seq %d, actual %s", last+1, scanner.Text())
+			break
+		}
+		last = response.Seq
+	}
+	wg.Wait()
+	conn.Close()
+	server.Close()
+}
@@ -1,41 +1,115 @@
 package jsonrpc
 
 import (
+	"encoding/json"
 	"net"
 	"sync"
 )
 
 // Close takes the listen and close channel and closes them
 func (s *Server) Close() error {
-	return s.listen.Close()
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	var err error
+	for _, listen := range s.listeners() {
+		if err1 := listen.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+	}
+	return err
 }
 
+// maxQueuedBroadcasts is the count of messages that may wait to be written
+// to a conn. A conn that falls further behind is closed
+const maxQueuedBroadcasts = 256
+
 // Broadcast sends to all the active connections the given message
 func (s *Server) Broadcast(msg []byte) {
-	for _, conn := range s.conns {
-		if err := s.write(conn, msg); err != nil {
+	s.publish(msg, s.connections())
+}
+
+// publish numbers the message and queues it for the given conns
+func (s *Server) publish(msg []byte, conns []net.Conn) {
+	s.replays.record(msg, func(numbered []byte) {
+		s.fanOut(numbered, conns)
+	})
+}
+
+// fanOut queues the given message for the given conns, encoding it once
+// for each codec and compression
+func (s *Server) fanOut(msg []byte, conns []net.Conn) {
+	written, failures := 0, 0
+	encoded := make(map[string][]byte)
+	for _, conn := range conns {
+		payload, err := s.transcode(msg, s.encoding(conn), encoded)
+		if err != nil || !s.enqueue(conn, payload) {
 			//log.Println("Broadcast", err)
+			failures++
+			continue
+		}
+		written += len(payload)
+	}
+	s.metrics.observeBroadcast(written, failures)
+}
+
+// enqueue hands the message to the goroutine that writes the broadcasts of
+// the conn, so they are written in the order they were queued without
+// waiting for the client. A conn whose queue is full is closed
+func (s *Server) enqueue(conn net.Conn, msg []byte) bool {
+	session := s.session(conn)
+	if session == nil {
+		return false
+	}
+	select {
+	case session.outbox <- msg:
+		return true
+	default:
+		conn.Close()
+		return false
+	}
+}
+
+// writeQueued writes the messages queued for the conn until it is unplugged
+func (s *Server) writeQueued(conn net.Conn, session *session) {
+	for {
+		select {
+		case msg := <-session.outbox:
+			if err := s.write(conn, msg); err != nil {
+				//log.Println("writeQueued:write", err)
+			}
+		case <-session.closed:
+			return
 		}
 	}
 }
 
 // BroadcastError takes a JSON-RPC error and sends it to all connections
 func (s *Server) BroadcastError(base *Base, response *Error) {
-	for _, conn := range s.conns {
-		if err := s.sendError(conn, base, response); err != nil {
-			//log.Println("BroadcastError", err)
-		}
+	msg, err := json.Marshal(&Response{
+		Base:  *base,
+		Error: response,
+	})
+	if err != nil {
+		//log.Println("BroadcastError", err)
+		return
 	}
+	s.Broadcast(msg)
 }
 
-func (s *Server) startListen(listen net.Listener) {
+func (s *Server) startListen(listen net.Listener, options *ListenerOptions) {
 	for {
 		conn, err := listen.Accept()
 		if err != nil {
 			// aqui hay un error en potencia
 			return
 		}
-		s.plug(conn)
+		s.metrics.observeAccept()
+		if s.full() {
+			go s.reject(conn)
+			continue
+		}
+		s.plug(conn, options)
 		go (func(c net.Conn) {
 			s.handleClient(c)
 			s.unplug(c)
@@ -45,19 +119,38 @@ func (s *Server) startListen(listen net.Listener) {
 
 // Start prepares and launches the json-rpc server
 func (s *Server) Start() (err error) {
-	listen, err := net.Listen("tcp", s.Address)
+	listen, err := s.listenAddress()
 	if err != nil {
 		return err
 	}
+	return s.Serve(listen)
+}
 
-	go s.startListen(listen)
-
+// Serve prepares and launches the json-rpc server on the given listener,
+// which is closed by Close
+func (s *Server) Serve(listen net.Listener) error {
+	replays, err := s.newReplay()
+	if err != nil {
+		return err
+	}
+	s.replays = replays
 	s.plugBlocker = &sync.Mutex{}
-	s.writeBlocker = &sync.Mutex{}
 	s.conns = make([]net.Conn, 0)
-	s.listen = listen
-	s.registersSource = make(map[string]map[string]RemoteProcedure)
-	s.registersTarget = make(map[string]map[string]DBRemoteProcedure)
+	s.sessions = make(map[net.Conn]*session)
+	s.listens = []net.Listener{listen}
+	s.registerBlocker = &sync.Mutex{}
+	s.registers.Store(newRegistry())
+	s.metrics = newMetrics()
+	s.done = make(chan struct{})
+
+	options := s.ListenerOptions
+	go s.startListen(listen, &options)
+	if s.HeartbeatInterval > 0 {
+		go s.heartbeat()
+	}
+	if s.NotificationStore != nil {
+		go s.storeNotifications()
+	}
 
 	return nil
 }
@@ -0,0 +1,64 @@
+package jsonrpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// session keeps the state that belongs to a single plugged connection
+type session struct {
+	lastSeen int64
+	callID   uint64
+	id       string
+	blocker  *sync.Mutex
+	writer   *sync.Mutex
+	conn     net.Conn
+	limiter  *connLimiter
+	wire     wire
+	options  *ListenerOptions
+	calls    map[string]chan *Response
+	outbox   chan []byte
+	closed   chan struct{}
+	stopped  chan struct{}
+	groups   map[string]bool
+	inflight map[string]context.CancelFunc
+	streams  map[string]*Stream
+}
+
+func (s *Server) newSession(
+	conn net.Conn, options *ListenerOptions) *session {
+	framer := options.Framer
+	if framer == nil {
+		framer = s.framer()
+	}
+	return &session{
+		lastSeen: time.Now().UnixNano(),
+		id:       s.nextConnID(),
+		blocker:  &sync.Mutex{},
+		writer:   &sync.Mutex{},
+		conn:     conn,
+		limiter:  newConnLimiter(s.RateLimits),
+		wire:     wire{framer: framer, codec: JSONCodec{}},
+		options:  options,
+		calls:    make(map[string]chan *Response),
+		outbox:   make(chan []byte, maxQueuedBroadcasts),
+		closed:   make(chan struct{}),
+		stopped:  make(chan struct{}),
+		groups:   make(map[string]bool),
+		inflight: make(map[string]context.CancelFunc),
+		streams:  make(map[string]*Stream),
+	}
+}
+
+// session returns the state of the given conn, or nil if conn is not
+// plugged to the server
+func (s *Server) session(conn net.Conn) *session {
+	if conn == nil {
+		return nil
+	}
+	s.plugBlocker.Lock()
+	defer s.plugBlocker.Unlock()
+	return s.sessions[conn]
+}
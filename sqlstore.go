@@ -0,0 +1,88 @@
+package jsonrpc
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SQLNotificationStore keeps the notifications in a table of a PostgreSQL
+// database. CreateTable creates the table if it does not exist
+type SQLNotificationStore struct {
+	DB    *sql.DB
+	Table string
+}
+
+// table returns the name of the table or its default
+func (store *SQLNotificationStore) table() string {
+	if store.Table != "" {
+		return store.Table
+	}
+	return "arca_notifications"
+}
+
+// CreateTable creates the table of the notifications
+func (store *SQLNotificationStore) CreateTable() error {
+	_, err := store.DB.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		Seq BIGINT PRIMARY KEY,
+		Context TEXT NOT NULL,
+		Message TEXT NOT NULL,
+		At TIMESTAMP WITH TIME ZONE NOT NULL
+	)`, store.table()))
+	if err != nil {
+		return err
+	}
+	_, err = store.DB.Exec(fmt.Sprintf(
+		`CREATE INDEX IF NOT EXISTS %s_context ON %s (Context, Seq)`,
+		store.table(), store.table()))
+	return err
+}
+
+// Append inserts the notification
+func (store *SQLNotificationStore) Append(notification *Notification) error {
+	_, err := store.DB.Exec(fmt.Sprintf(
+		`INSERT INTO %s (Seq, Context, Message, At) VALUES ($1, $2, $3, $4)`,
+		store.table()),
+		int64(notification.Seq), notification.Context,
+		string(notification.Message), notification.At)
+	return err
+}
+
+// Since selects the notifications that follow seq in the given context
+func (store *SQLNotificationStore) Since(
+	seq uint64, context string, limit int) ([]*Notification, error) {
+	rows, err := store.DB.Query(fmt.Sprintf(
+		`SELECT Seq, Context, Message, At FROM %s
+		WHERE Seq > $1 AND ($2 = '' OR Context = $2)
+		ORDER BY Seq LIMIT $3`, store.table()),
+		int64(seq), context, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]*Notification, 0)
+	for rows.Next() {
+		var seq int64
+		var message string
+		notification := &Notification{}
+		if err := rows.Scan(&seq, &notification.Context,
+			&message, &notification.At); err != nil {
+			return nil, err
+		}
+		notification.Seq = uint64(seq)
+		notification.Message = []byte(message)
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+// Last selects the greatest Seq of the table
+func (store *SQLNotificationStore) Last() (uint64, error) {
+	var last sql.NullInt64
+	err := store.DB.QueryRow(fmt.Sprintf(
+		`SELECT MAX(Seq) FROM %s`, store.table())).Scan(&last)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(last.Int64), nil
+}
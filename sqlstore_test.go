@@ -0,0 +1,200 @@
+package jsonrpc
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotificationsDriver is a database/sql driver that understands the
+// statements of SQLNotificationStore and keeps the rows in memory
+type fakeNotificationsDriver struct {
+	blocker sync.Mutex
+	tables  map[string][][]driver.Value
+	queries []string
+}
+
+var fakeNotifications = &fakeNotificationsDriver{
+	tables: make(map[string][][]driver.Value)}
+
+func init() {
+	sql.Register("arca-fake-notifications", fakeNotifications)
+}
+
+func (d *fakeNotificationsDriver) Open(name string) (driver.Conn, error) {
+	return &fakeNotificationsConn{driver: d}, nil
+}
+
+type fakeNotificationsConn struct {
+	driver *fakeNotificationsDriver
+}
+
+func (c *fakeNotificationsConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeNotificationsStmt{driver: c.driver, query: query}, nil
+}
+
+func (c *fakeNotificationsConn) Close() error {
+	return nil
+}
+
+func (c *fakeNotificationsConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions not supported")
+}
+
+type fakeNotificationsStmt struct {
+	driver *fakeNotificationsDriver
+	query  string
+}
+
+func (st *fakeNotificationsStmt) Close() error {
+	return nil
+}
+
+func (st *fakeNotificationsStmt) NumInput() int {
+	return -1
+}
+
+// table returns the name that follows the given keyword in the query
+func (st *fakeNotificationsStmt) table(keyword string) string {
+	fields := strings.Fields(st.query[strings.Index(st.query, keyword)+len(keyword):])
+	return fields[0]
+}
+
+func (st *fakeNotificationsStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := st.driver
+	d.blocker.Lock()
+	defer d.blocker.Unlock()
+	d.queries = append(d.queries, st.query)
+	if strings.HasPrefix(st.query, "INSERT INTO") {
+		table := st.table("INSERT INTO")
+		for _, row := range d.tables[table] {
+			if row[0] == args[0] {
+				return nil, errors.New("duplicate key")
+			}
+		}
+		d.tables[table] = append(d.tables[table], args)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (st *fakeNotificationsStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := st.driver
+	d.blocker.Lock()
+	defer d.blocker.Unlock()
+	d.queries = append(d.queries, st.query)
+	rows := d.tables[st.table("FROM")]
+	if strings.Contains(st.query, "MAX(Seq)") {
+		var last driver.Value
+		for _, row := range rows {
+			if last == nil || row[0].(int64) > last.(int64) {
+				last = row[0]
+			}
+		}
+		return &fakeNotificationsRows{
+			columns: []string{"max"}, rows: [][]driver.Value{{last}}}, nil
+	}
+	seq, context, limit := args[0].(int64), args[1].(string), args[2].(int64)
+	selected := make([][]driver.Value, 0)
+	for _, row := range rows {
+		if int64(len(selected)) == limit {
+			break
+		}
+		if row[0].(int64) > seq && (context == "" || row[1] == context) {
+			selected = append(selected, row)
+		}
+	}
+	return &fakeNotificationsRows{
+		columns: []string{"seq", "context", "message", "at"},
+		rows:    selected}, nil
+}
+
+type fakeNotificationsRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeNotificationsRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeNotificationsRows) Close() error {
+	return nil
+}
+
+func (r *fakeNotificationsRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func Test_SQLNotificationStore_Append_Since_Last__OK(t *testing.T) {
+	fakeNotifications.blocker.Lock()
+	fakeNotifications.tables = make(map[string][][]driver.Value)
+	fakeNotifications.queries = nil
+	fakeNotifications.blocker.Unlock()
+
+	db, err := sql.Open("arca-fake-notifications", "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.Close()
+	store := &SQLNotificationStore{DB: db, Table: "test_notifications"}
+	if err := store.CreateTable(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if last, err := store.Last(); err != nil || last != 0 {
+		t.Error("Expected last sequence 0, got", last, err)
+	}
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for seq, context := range []string{"Project", "Other", "Project"} {
+		if err := store.Append(&Notification{
+			Seq:     uint64(seq + 1),
+			Context: context,
+			Message: json.RawMessage(`{"Seq":1}`),
+			At:      at,
+		}); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := store.Append(&Notification{Seq: 1}); err == nil {
+		t.Error("Expecting a duplicated Seq to fail")
+	}
+
+	notifications, err := store.Since(1, "Project", 10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(notifications) != 1 || notifications[0].Seq != 3 ||
+		notifications[0].Context != "Project" ||
+		string(notifications[0].Message) != `{"Seq":1}` ||
+		!notifications[0].At.Equal(at) {
+		t.Error("Unexpected notifications", notifications)
+	}
+	if notifications, _ := store.Since(0, "", 2); len(notifications) != 2 {
+		t.Error("Expected 2 notifications, got", len(notifications))
+	}
+	if last, err := store.Last(); err != nil || last != 3 {
+		t.Error("Expected last sequence 3, got", last, err)
+	}
+
+	fakeNotifications.blocker.Lock()
+	defer fakeNotifications.blocker.Unlock()
+	for _, query := range fakeNotifications.queries {
+		if !strings.Contains(query, "test_notifications") {
+			t.Error("Expected the configured table in", query)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultNotificationsLimit is the count of notifications that
+// rpc.notifications returns when no Limit is given, and the most it returns
+const defaultNotificationsLimit = 1000
+
+// maxQueuedNotifications is the count of notifications that may wait to be
+// appended to the NotificationStore. The ones that do not fit are dropped
+const maxQueuedNotifications = 1024
+
+var (
+	errNoNotificationStore     = errors.New("Notification store is disabled")
+	errNotificationStoreBehind = errors.New("Notification store is behind")
+)
+
+// Notification is a broadcasted message as kept by a NotificationStore.
+// Context is the Source of the context of the message, if any
+type Notification struct {
+	Seq     uint64
+	Context string
+	Message json.RawMessage
+	At      time.Time
+}
+
+// NotificationStore keeps every broadcasted message so clients that were
+// offline for long can catch up with rpc.notifications
+type NotificationStore interface {
+	// Append keeps the notification. Notifications come in order of Seq
+	Append(notification *Notification) error
+	// Since returns up to limit notifications whose Seq is greater than
+	// seq, in order of Seq. An empty context matches all of them
+	Since(seq uint64, context string, limit int) ([]*Notification, error)
+	// Last returns the greatest Seq kept, so the server continues from it
+	Last() (uint64, error)
+}
+
+// MemoryNotificationStore keeps the notifications in memory. Useful for
+// tests
+type MemoryNotificationStore struct {
+	blocker       sync.Mutex
+	notifications []*Notification
+}
+
+// Append keeps the notification
+func (m *MemoryNotificationStore) Append(notification *Notification) error {
+	m.blocker.Lock()
+	defer m.blocker.Unlock()
+	m.notifications = append(m.notifications, notification)
+	return nil
+}
+
+// Since returns the notifications that follow seq in the given context
+func (m *MemoryNotificationStore) Since(
+	seq uint64, context string, limit int) ([]*Notification, error) {
+	m.blocker.Lock()
+	defer m.blocker.Unlock()
+	notifications := make([]*Notification, 0)
+	for _, notification := range m.notifications {
+		if len(notifications) == limit {
+			break
+		}
+		if notification.Seq > seq &&
+			(context == "" || notification.Context == context) {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
+
+// Last returns the Seq of the last notification
+func (m *MemoryNotificationStore) Last() (uint64, error) {
+	m.blocker.Lock()
+	defer m.blocker.Unlock()
+	if len(m.notifications) == 0 {
+		return 0, nil
+	}
+	return m.notifications[len(m.notifications)-1].Seq, nil
+}
+
+// storeNotifications appends the queued notifications to the store, in
+// order, until the server is closed. Broadcasts do not wait for the store
+func (s *Server) storeNotifications() {
+	for {
+		select {
+		case notification := <-s.replays.pending:
+			if err := s.NotificationStore.Append(notification); err != nil {
+				s.storeFailed(notification, err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// storeFailed reports a notification that did not reach the store
+func (s *Server) storeFailed(notification *Notification, err error) {
+	s.metrics.observeStoreFailure()
+	if s.OnStoreError != nil {
+		s.OnStoreError(notification, err)
+	}
+}
+
+// notificationContext returns the Source of the context of the given
+// message, or an empty string if it has none
+func notificationContext(msg []byte) string {
+	var base Base
+	if err := json.Unmarshal(msg, &base); err != nil {
+		return ""
+	}
+	ctx, err := getFieldFromContext("Source", base.Context)
+	if err != nil {
+		return ""
+	}
+	return ctx
+}
+
+// notificationsQuery is the Params of rpc.notifications
+type notificationsQuery struct {
+	Seq     uint64
+	Context string
+	Limit   int
+}
+
+// reachableNotifications returns the notifications of the query whose
+// context can be reached from the listener of the conn. The store is read
+// again while the ones left out leave room under the limit
+func (s *Server) reachableNotifications(
+	conn net.Conn, query notificationsQuery) ([]*Notification, error) {
+	notifications := make([]*Notification, 0)
+	seq := query.Seq
+	for len(notifications) < query.Limit {
+		batch, err := s.NotificationStore.Since(
+			seq, query.Context, query.Limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, notification := range batch {
+			seq = notification.Seq
+			if s.reachable(conn, notification.Context) {
+				notifications = append(notifications, notification)
+				if len(notifications) == query.Limit {
+					break
+				}
+			}
+		}
+		if len(batch) < query.Limit {
+			break
+		}
+	}
+	return notifications, nil
+}
+
+// processNotifications answers the reserved method rpc.notifications, whose
+// Params is an object with the Seq of the last message the client received,
+// and optionally a Context and a Limit. The answer lists the following
+// notifications kept by the NotificationStore. It returns false if the
+// request is not rpc.notifications
+func (s *Server) processNotifications(request *Request, conn net.Conn) bool {
+	if request.Method != "rpc.notifications" {
+		return false
+	}
+	query := notificationsQuery{}
+	raw, err := json.Marshal(request.Params)
+	if err == nil && request.Params != nil {
+		err = json.Unmarshal(raw, &query)
+	}
+	if query.Limit <= 0 || query.Limit > defaultNotificationsLimit {
+		query.Limit = defaultNotificationsLimit
+	}
+	var notifications []*Notification
+	if err == nil {
+		if s.NotificationStore == nil {
+			err = errNoNotificationStore
+		} else {
+			notifications, err = s.reachableNotifications(conn, query)
+		}
+	}
+	if err != nil {
+		if err := s.sendError(conn, &request.Base, &Error{
+			Message: "Invalid params",
+			Code:    -32602,
+			Data: map[string]string{
+				"Method": request.Method,
+				"ID":     request.ID,
+				"Error":  err.Error(),
+			},
+		}); err != nil {
+			//log.Println("processNotifications:sendError", err)
+		}
+		return true
+	}
+
+	result := make([]map[string]interface{}, 0, len(notifications))
+	for _, notification := range notifications {
+		var message interface{}
+		if err := json.Unmarshal(notification.Message, &message); err != nil {
+			//log.Println("processNotifications:Unmarshal", err)
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"Seq":     notification.Seq,
+			"Context": notification.Context,
+			"Message": message,
+			"At":      notification.At,
+		})
+	}
+	if err := s.send(conn, &Response{
+		Base:   request.Base,
+		Result: result,
+	}); err != nil {
+		//log.Println("processNotifications:send", err)
+	}
+	return true
+}
@@ -0,0 +1,209 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_NotificationStore_Query__OK(t *testing.T) {
+	store := &MemoryNotificationStore{}
+	store.Append(&Notification{
+		Seq:     5,
+		Context: "Project",
+		Message: json.RawMessage(`{"Method":"Old","Seq":5}`),
+		At:      time.Now(),
+	})
+	server := &Server{Address: address, NotificationStore: store}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	server.Notify("Updated", map[string]interface{}{"Source": "Project"}, "1")
+	server.Notify("Updated", "Other", "2")
+	scanner := bufio.NewScanner(conn)
+	scanner.Scan()
+	expected := `{"ID":"","Method":"Updated","Context":{"Source":"Project"},"Result":"1","Error":null,"Seq":6}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+	scanner.Scan()
+	waitLast(store, 7)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.notifications"
+	request.Params = map[string]interface{}{"Seq": 5, "Context": "Project"}
+	response := struct {
+		Result []struct {
+			Seq     uint64
+			Context string
+			Message json.RawMessage
+		}
+	}{}
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+	scanner.Scan()
+	if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+		t.Error(err)
+	}
+	if len(response.Result) != 1 || response.Result[0].Seq != 6 ||
+		response.Result[0].Context != "Project" {
+		t.Error("Unexpected notifications", response.Result)
+	}
+	expected = `{"Context":{"Source":"Project"},"Error":null,"ID":"","Method":"Updated","Result":"1","Seq":6}`
+	assertExpectedVsActualAndClose(
+		t, expected, string(response.Result[0].Message), nil)
+
+	if last, _ := store.Last(); last != 7 {
+		t.Error("Expected last sequence 7, got", last)
+	}
+	conn.Close()
+	server.Close()
+}
+
+func Test_NotificationStore_Limit_Capped__OK(t *testing.T) {
+	store := &MemoryNotificationStore{}
+	for seq := uint64(1); seq <= defaultNotificationsLimit+1; seq++ {
+		store.Append(&Notification{
+			Seq:     seq,
+			Context: "Project",
+			Message: json.RawMessage(`{}`),
+			At:      time.Now(),
+		})
+	}
+	server, conn, err := startGivenServerAndClient(t,
+		&Server{Address: address, NotificationStore: store})
+	if err != nil {
+		return
+	}
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.notifications"
+	request.Params = map[string]interface{}{"Seq": 0, "Limit": 1e9}
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	scanner.Scan()
+	response := struct {
+		Result []struct{ Seq uint64 }
+	}{}
+	if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+		t.Error(err)
+	}
+	if len(response.Result) != defaultNotificationsLimit {
+		t.Error("Expected", defaultNotificationsLimit,
+			"notifications, got", len(response.Result))
+	}
+	conn.Close()
+	server.Close()
+}
+
+// waitLast waits for the notifications to be appended up to the given Seq
+func waitLast(store NotificationStore, seq uint64) {
+	for i := 0; i < 100; i++ {
+		if last, _ := store.Last(); last >= seq {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_NotificationStore_Restricted_Listener__OK(t *testing.T) {
+	store := &MemoryNotificationStore{}
+	server := &Server{Address: address, NotificationStore: store,
+		ListenerOptions: ListenerOptions{Contexts: []string{"Admin"}}}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	server.Notify("Updated", map[string]interface{}{"Source": "Global"}, "1")
+	server.Notify("Updated", map[string]interface{}{"Source": "Admin/users"}, "2")
+	server.Notify("Updated", map[string]interface{}{"Source": "Global"}, "3")
+	waitLast(store, 3)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "rpc.notifications"
+	request.Params = map[string]interface{}{"Seq": 0, "Limit": 1}
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+
+	response := struct {
+		Result []struct {
+			Seq     uint64
+			Context string
+		}
+	}{}
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), `{"ID":"ID"`) {
+			if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+				t.Error(err)
+			}
+			break
+		}
+	}
+	if len(response.Result) != 1 || response.Result[0].Seq != 2 ||
+		response.Result[0].Context != "Admin/users" {
+		t.Error("Unexpected notifications", response.Result)
+	}
+	conn.Close()
+	server.Close()
+}
+
+// failingStore is a NotificationStore whose Append always fails
+type failingStore struct {
+	MemoryNotificationStore
+}
+
+func (*failingStore) Append(notification *Notification) error {
+	return errors.New("disk full")
+}
+
+func Test_NotificationStore_Append_Failure_Reported__OK(t *testing.T) {
+	failures := make(chan error, 1)
+	server := &Server{Address: address, NotificationStore: &failingStore{},
+		OnStoreError: func(notification *Notification, err error) {
+			failures <- err
+		}}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	server.Notify("Updated", "Global", "1")
+	select {
+	case err := <-failures:
+		assertExpectedVsActualAndClose(t, "disk full", err.Error(), nil)
+	case <-time.After(time.Second):
+		t.Error("Expecting the failure to be reported")
+	}
+
+	var metrics strings.Builder
+	server.WriteMetrics(&metrics)
+	if !strings.Contains(metrics.String(),
+		"arca_jsonrpc_store_failures_total 1") {
+		t.Error("Expecting the failure to be counted", metrics.String())
+	}
+	server.Close()
+}
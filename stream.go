@@ -0,0 +1,157 @@
+package jsonrpc
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// defaultStreamWindow is the StreamWindow used when none is given
+const defaultStreamWindow = 16
+
+var errStreamClosed = errors.New("Stream closed")
+
+// StreamProcedure represents the function-handler of a streaming method.
+// It sends partial results through the stream and its result is the
+// terminal response of the request
+type StreamProcedure func(
+	request *Request, stream *Stream) (result interface{}, err error)
+
+// Stream sends the partial results of a request to the conn that made it.
+// The client acknowledges them with $/streamAck, and at most StreamWindow
+// of them wait for acknowledgement, so a slow client slows the handler down
+// instead of piling up messages. Once the handler returns the stream is
+// closed, so no partial result follows the terminal response
+type Stream struct {
+	blocker sync.Mutex
+	server  *Server
+	conn    net.Conn
+	request *Request
+	credits chan struct{}
+	count   int
+	closed  bool
+}
+
+// RegisterStream registers a streaming method in the Source hierarchy. If
+// the handler returns a nil result, the terminal response carries the count
+// of partial results sent
+func (s *Server) RegisterStream(
+	method string, context string, sp StreamProcedure) {
+	s.RegisterSource(method, context,
+		func(request *Request) (result interface{}, err error) {
+			stream := s.newStream(request)
+			if session := s.session(request.conn); session != nil {
+				session.blocker.Lock()
+				session.streams[request.ID] = stream
+				session.blocker.Unlock()
+				defer func() {
+					session.blocker.Lock()
+					delete(session.streams, request.ID)
+					session.blocker.Unlock()
+				}()
+			}
+			result, err = sp(request, stream)
+			count := stream.close()
+			if result == nil && err == nil {
+				result = count
+			}
+			return
+		})
+}
+
+func (s *Server) newStream(request *Request) *Stream {
+	window := s.StreamWindow
+	if window <= 0 {
+		window = defaultStreamWindow
+	}
+	stream := &Stream{
+		server:  s,
+		conn:    request.conn,
+		request: request,
+		credits: make(chan struct{}, window),
+	}
+	for i := 0; i < window; i++ {
+		stream.credits <- struct{}{}
+	}
+	return stream
+}
+
+// Send sends a partial result. It waits while the window is full and fails
+// if the request is cancelled or its deadline passes meanwhile, or if the
+// handler already returned
+func (stream *Stream) Send(partial interface{}) error {
+	ctx := stream.request.Ctx()
+	select {
+	case <-stream.credits:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	stream.blocker.Lock()
+	defer stream.blocker.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if stream.closed {
+		return errStreamClosed
+	}
+	stream.count++
+	return stream.server.send(stream.conn, &Response{
+		Base:    stream.request.Base,
+		Result:  partial,
+		Partial: true,
+	})
+}
+
+// close stops the stream once any Send in course is done and returns the
+// count of partial results sent
+func (stream *Stream) close() int {
+	stream.blocker.Lock()
+	defer stream.blocker.Unlock()
+	stream.closed = true
+	return stream.count
+}
+
+// ack gives back credits to the stream
+func (stream *Stream) ack(count int) {
+	for i := 0; i < count; i++ {
+		select {
+		case stream.credits <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// closeStream closes the stream of the request with the given ID, if any,
+// so no partial result follows its terminal response
+func (session *session) closeStream(id string) {
+	session.blocker.Lock()
+	stream := session.streams[id]
+	session.blocker.Unlock()
+	if stream != nil {
+		stream.close()
+	}
+}
+
+// processStreamAck handles the reserved notification $/streamAck, whose
+// Params is an object with the ID of a streaming request and the Count of
+// partial results the client processed, one if not given. Nothing is
+// answered. It returns false if the request is not $/streamAck
+func (s *Server) processStreamAck(request *Request, session *session) bool {
+	if request.Method != "$/streamAck" {
+		return false
+	}
+	params, _ := request.Params.(map[string]interface{})
+	id, _ := params["ID"].(string)
+	count := 1
+	if value, ok := params["Count"].(float64); ok {
+		count = int(value)
+	}
+	session.blocker.Lock()
+	stream := session.streams[id]
+	session.blocker.Unlock()
+	if stream != nil {
+		stream.ack(count)
+	}
+	return true
+}
@@ -0,0 +1,164 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Stream_Partial_Results_With_Window__OK(t *testing.T) {
+	server := &Server{Address: address, StreamWindow: 2}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	server.RegisterStream("Export", "Projects",
+		func(request *Request, stream *Stream) (result interface{}, err error) {
+			for i := 1; i <= 3; i++ {
+				if err = stream.Send(i); err != nil {
+					return
+				}
+			}
+			return
+		})
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	scanner := bufio.NewScanner(conn)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Export"
+	request.Context = "Projects"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected := `{"ID":"ID","Method":"Export","Context":"Projects","Result":1,"Error":null,"Partial":true}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+	scanner.Scan()
+	expected = `{"ID":"ID","Method":"Export","Context":"Projects","Result":2,"Error":null,"Partial":true}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+
+	// The window is full, so nothing else comes until an ack
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if scanner.Scan() {
+		t.Error("Unexpected message before the ack", scanner.Text())
+	}
+	conn.SetReadDeadline(time.Time{})
+	scanner = bufio.NewScanner(conn)
+
+	ack := Request{}
+	ack.Method = "$/streamAck"
+	ack.Params = map[string]interface{}{"ID": "ID", "Count": 2}
+	msg, _ = json.Marshal(&ack)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected = `{"ID":"ID","Method":"Export","Context":"Projects","Result":3,"Error":null,"Partial":true}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+	scanner.Scan()
+	expected = `{"ID":"ID","Method":"Export","Context":"Projects","Result":3,"Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), server)
+	conn.Close()
+}
+
+func Test_Stream_Reserved_Methods_Do_Not_Block_Ack__OK(t *testing.T) {
+	server, conn, err := startGivenServerAndClient(t,
+		&Server{Address: address, StreamWindow: 1})
+	if err != nil {
+		return
+	}
+	server.RegisterStream("Export", "Projects",
+		func(request *Request, stream *Stream) (result interface{}, err error) {
+			for i := 1; i <= 2; i++ {
+				if err = stream.Send(i); err != nil {
+					return
+				}
+			}
+			return
+		})
+	scanner := bufio.NewScanner(conn)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Export"
+	request.Context = "Projects"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected := `{"ID":"ID","Method":"Export","Context":"Projects","Result":1,"Error":null,"Partial":true}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+
+	// The stream waits for an ack while the client answers a heartbeat
+	pong := Request{}
+	pong.Method = "rpc.pong"
+	msg, _ = json.Marshal(&pong)
+	send(&conn, msg)
+
+	ping := Request{}
+	ping.ID = "Ping"
+	ping.Method = "rpc.ping"
+	msg, _ = json.Marshal(&ping)
+	send(&conn, msg)
+	scanner.Scan()
+	expected = `{"ID":"Ping","Method":"rpc.ping","Context":null,"Result":"pong","Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+
+	ack := Request{}
+	ack.Method = "$/streamAck"
+	ack.Params = map[string]interface{}{"ID": "ID", "Count": 1}
+	msg, _ = json.Marshal(&ack)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected = `{"ID":"ID","Method":"Export","Context":"Projects","Result":2,"Error":null,"Partial":true}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+	ack.Params = map[string]interface{}{"ID": "ID", "Count": 1}
+	msg, _ = json.Marshal(&ack)
+	send(&conn, msg)
+	scanner.Scan()
+	expected = `{"ID":"ID","Method":"Export","Context":"Projects","Result":2,"Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), server)
+	conn.Close()
+}
+
+func Test_Stream_Send_After_Response__Fail(t *testing.T) {
+	server, conn, err := startServerAndClient(t)
+	if err != nil {
+		return
+	}
+	var late *Stream
+	server.RegisterStream("Export", "Projects",
+		func(request *Request, stream *Stream) (result interface{}, err error) {
+			late = stream
+			return "done", nil
+		})
+	scanner := bufio.NewScanner(conn)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Export"
+	request.Context = "Projects"
+	msg, _ := json.Marshal(&request)
+	send(&conn, msg)
+
+	scanner.Scan()
+	expected := `{"ID":"ID","Method":"Export","Context":"Projects","Result":"done","Error":null}`
+	assertExpectedVsActualAndClose(t, expected, scanner.Text(), nil)
+
+	if err := late.Send(1); err == nil {
+		t.Error("Expecting Send to fail after the response")
+	}
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if scanner.Scan() {
+		t.Error("Unexpected message after the response", scanner.Text())
+	}
+	server.Close()
+	conn.Close()
+}
@@ -0,0 +1,140 @@
+package jsonrpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span represents the execution of a handler. Its identifiers follow the
+// W3C trace-context format so they can be bridged to OpenTelemetry
+type Span struct {
+	Name       string
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	Err        error
+}
+
+// Traceparent returns the W3C traceparent header of the span. Store it
+// wherever the work continues (e.g. the payload of a NOTIFY) and put it back
+// in Request.Trace so the next span joins the same trace
+func (span *Span) Traceparent() string {
+	if span == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", span.TraceID, span.SpanID)
+}
+
+// SpanExporter receives every finished span
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// InMemoryExporter keeps the finished spans in memory. Useful for tests
+type InMemoryExporter struct {
+	blocker sync.Mutex
+	spans   []*Span
+}
+
+// ExportSpan appends the span to the list of finished spans
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.blocker.Lock()
+	defer e.blocker.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the finished spans in the order they were exported
+func (e *InMemoryExporter) Spans() []*Span {
+	e.blocker.Lock()
+	defer e.blocker.Unlock()
+	spans := make([]*Span, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+// Reset drops all the finished spans
+func (e *InMemoryExporter) Reset() {
+	e.blocker.Lock()
+	defer e.blocker.Unlock()
+	e.spans = nil
+}
+
+// Span returns the span wrapping the execution of the handler that
+// received the request, or nil if the request is not being handled
+func (request *Request) Span() *Span {
+	return request.span
+}
+
+// startSpan opens a span for the handler that matches the given source,
+// context and request. If the request carries a valid traceparent then the
+// span becomes its child, otherwise a new trace starts
+func startSpan(source, ctx string, request *Request) *Span {
+	span := &Span{
+		Name:   fmt.Sprintf("%s %s/%s", source, ctx, request.Method),
+		SpanID: randomHex(8),
+		Start:  time.Now(),
+		Attributes: map[string]string{
+			"rpc.system":             "jsonrpc",
+			"rpc.method":             request.Method,
+			"rpc.jsonrpc.request_id": request.ID,
+			"arca.source":            source,
+			"arca.context":           ctx,
+		},
+	}
+	if traceID, parentID, ok := parseTraceparent(request.Trace); ok {
+		span.TraceID = traceID
+		span.ParentID = parentID
+	} else {
+		span.TraceID = randomHex(16)
+	}
+	return span
+}
+
+// endSpan closes the span and hands it to the exporter, if any
+func (s *Server) endSpan(span *Span, err error) {
+	span.End = time.Now()
+	span.Err = err
+	if s.SpanExporter != nil {
+		s.SpanExporter.ExportSpan(span)
+	}
+}
+
+// parseTraceparent extracts the trace and parent identifiers from a W3C
+// traceparent header
+func parseTraceparent(
+	traceparent string) (traceID string, parentID string, ok bool) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return
+	}
+	if !isHex(parts[1]) || !isHex(parts[2]) ||
+		parts[1] == strings.Repeat("0", 32) ||
+		parts[2] == strings.Repeat("0", 16) {
+		return
+	}
+	return parts[1], parts[2], true
+}
+
+// isHex tells if value only contains lowercase hexadecimal digits
+func isHex(value string) bool {
+	for _, c := range value {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes encoded as hexadecimal
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,81 @@
+package jsonrpc
+
+import (
+	"database/sql"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Tracing_Source_Propagates_To_Target__OK(t *testing.T) {
+	exporter := &InMemoryExporter{}
+	server := &Server{Address: address, SpanExporter: exporter}
+	if err := server.Start(); err != nil {
+		t.Error(err)
+		return
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Error(err)
+		server.Close()
+		return
+	}
+
+	var traceparent string
+	edit :=
+		func(request *Request) (result interface{}, err error) {
+			traceparent = request.Span().Traceparent()
+			result = "Edited"
+			return
+		}
+	notify :=
+		func(db *sql.DB) RemoteProcedure {
+			return func(request *Request) (result interface{}, err error) {
+				return
+			}
+		}
+	server.RegisterSource("Edit", "Global", edit)
+	server.RegisterTarget("Edit", "Global", notify)
+
+	request := Request{}
+	request.ID = "ID"
+	request.Method = "Edit"
+	request.Context = "Global"
+	request.Trace = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	sendJSONAndReceive(&conn, &request)
+
+	time.Sleep(100 * time.Millisecond)
+	notification := Request{}
+	notification.ID = "ID"
+	notification.Method = "Edit"
+	notification.Context = map[string]interface{}{"Target": "Global"}
+	notification.Trace = traceparent
+	server.ProcessNotification(&notification, nil)
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Errorf("expect 2 spans, actual %d", len(spans))
+		server.Close()
+		return
+	}
+	source, target := spans[0], spans[1]
+	assertExpectedVsActualAndClose(t, "Source Global/Edit", source.Name, nil)
+	assertExpectedVsActualAndClose(t,
+		"0af7651916cd43dd8448eb211c80319c", source.TraceID, nil)
+	assertExpectedVsActualAndClose(t, "b7ad6b7169203331", source.ParentID, nil)
+	assertExpectedVsActualAndClose(t, "Target Global/Edit", target.Name, nil)
+	assertExpectedVsActualAndClose(t, source.TraceID, target.TraceID, nil)
+	assertExpectedVsActualAndClose(t, source.SpanID, target.ParentID, server)
+}
+
+func Test_Tracing_Invalid_Traceparent_Starts_New_Trace__OK(t *testing.T) {
+	traceID, parentID, ok := parseTraceparent("00-xyz-b7ad6b7169203331-01")
+	if ok || traceID != "" || parentID != "" {
+		t.Errorf("expect invalid traceparent, actual %s %s", traceID, parentID)
+	}
+
+	span := startSpan("Source", "Global", &Request{Trace: "garbage"})
+	if len(span.TraceID) != 32 || span.ParentID != "" {
+		t.Errorf("expect root span, actual %s %s", span.TraceID, span.ParentID)
+	}
+}
@@ -1,26 +1,90 @@
 package jsonrpc
 
 import (
+	"bufio"
+	"bytes"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"time"
 )
 
+// defaultMaxRequestSize is the MaxRequestSize used when none is given
+const defaultMaxRequestSize = 1 << 20
+
+// defaultWriteTimeout is the WriteTimeout used when none is given
+const defaultWriteTimeout = 10 * time.Second
+
 var (
 	errMethodNotMatch  = errors.New("Method not found")
 	errConnNilWhenSend = errors.New("Cannot send response if conn is nil")
 )
 
-// write sends the given message thorugh the given conn
+// ErrRequestTooLarge is returned by a Framer when a message is larger than
+// the maximum size. The message was discarded and the next one can be read
+var ErrRequestTooLarge = errors.New("Request too large")
+
+// readLine reads from reader until the next newline and returns the line
+// without the trailing CR LF. If the line is larger than max bytes, the rest
+// of it is discarded and ErrRequestTooLarge is returned, so the next call
+// can read the following line
+func readLine(reader *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	tooLarge := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLarge {
+			line = append(line, chunk...)
+			tooLarge = len(bytes.TrimRight(line, "\r\n")) > max
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0 || tooLarge) {
+			return nil, err
+		}
+		break
+	}
+	if tooLarge {
+		return nil, ErrRequestTooLarge
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// maxRequestSize returns the MaxRequestSize or its default
+func (s *Server) maxRequestSize() int {
+	if s.MaxRequestSize > 0 {
+		return s.MaxRequestSize
+	}
+	return defaultMaxRequestSize
+}
+
+// writeTimeout returns the WriteTimeout or its default. A negative
+// WriteTimeout disables the deadline
+func (s *Server) writeTimeout() time.Duration {
+	if s.WriteTimeout == 0 {
+		return defaultWriteTimeout
+	}
+	return s.WriteTimeout
+}
+
+// write sends the given message thorugh the given conn. Writes to the same
+// conn are serialized, and a conn that does not take the message before
+// WriteTimeout is closed, so a client that stops reading does not hold the
+// others back
 func (s *Server) write(conn net.Conn, msg []byte) error {
-	s.writeBlocker.Lock()
-	defer s.writeBlocker.Unlock()
-	if _, err := conn.Write(msg); err != nil {
-		return err
+	framer := s.encoding(conn).framer
+	if session := s.session(conn); session != nil {
+		session.writer.Lock()
+		defer session.writer.Unlock()
+	}
+	if timeout := s.writeTimeout(); timeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(timeout))
 	}
-	if _, err := conn.Write([]byte("\n")); err != nil {
+	if err := framer.WriteFrame(conn, msg); err != nil {
+		conn.Close()
 		return err
 	}
 	return nil
@@ -33,7 +97,7 @@ func (s *Server) send(conn net.Conn, response *Response) error {
 	if conn == nil {
 		return errConnNilWhenSend
 	}
-	msg, err = json.Marshal(response)
+	msg, err = s.marshal(conn, response)
 	if err != nil {
 		return err
 	}
@@ -51,7 +115,7 @@ func (s *Server) sendError(conn net.Conn, base *Base, err *Error) error {
 		Base:  *base,
 		Error: err,
 	}
-	msg, err1 = json.Marshal(response)
+	msg, err1 = s.marshal(conn, response)
 	if err1 != nil {
 		return err1
 	}
@@ -59,16 +123,21 @@ func (s *Server) sendError(conn net.Conn, base *Base, err *Error) error {
 }
 
 // plug appends a conn in the array of connections. Necessary for broadcasting
-func (s *Server) plug(conn net.Conn) {
+func (s *Server) plug(conn net.Conn, options *ListenerOptions) {
 	s.plugBlocker.Lock()
 	defer s.plugBlocker.Unlock()
 	s.conns = append(s.conns, conn)
+	s.sessions[conn] = s.newSession(conn, options)
 }
 
 // unplug drops a conn in the array of connections. Necessary for broadcasting
 func (s *Server) unplug(conn net.Conn) {
 	s.plugBlocker.Lock()
 	defer s.plugBlocker.Unlock()
+	if session, ok := s.sessions[conn]; ok {
+		close(session.closed)
+	}
+	delete(s.sessions, conn)
 	for i, value := range s.conns {
 		if value == conn {
 			s.conns[i] = s.conns[len(s.conns)-1]
@@ -78,6 +147,16 @@ func (s *Server) unplug(conn net.Conn) {
 	}
 }
 
+// connections returns a copy of the array of connections so it can be
+// traversed while other conns are plugged or unplugged
+func (s *Server) connections() []net.Conn {
+	s.plugBlocker.Lock()
+	defer s.plugBlocker.Unlock()
+	conns := make([]net.Conn, len(s.conns))
+	copy(conns, s.conns)
+	return conns
+}
+
 // getFieldFromContext extracts from the context the value of the given field
 func getFieldFromContext(
 	field string, context interface{}) (ctx string, err error) {
@@ -85,13 +164,16 @@ func getFieldFromContext(
 	case map[string]interface{}:
 		value := context.(map[string]interface{})[field]
 		if value != nil {
-			ctx = value.(string)
+			ctx, err = contextPath(value)
 		} else {
-			err = fmt.Errorf("Incorrect context %v", context)
+			err = errContextPath
 		}
 	case string:
-		ctx = context.(string)
+		ctx, err = contextPath(context)
 	default:
+		err = errContextPath
+	}
+	if err != nil {
 		err = fmt.Errorf("Incorrect context %v", context)
 	}
 	return
@@ -105,28 +187,25 @@ func (s *Server) findAndExecuteHandlerInTarget(
 	var result interface{}
 	var err error
 
-	if s.registersTarget[ctx] != nil {
-		found := s.registersTarget[ctx][request.Method]
-
-		if found != nil {
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						err = fmt.Errorf("%v", r)
-					}
-				}()
-				result, err = found(db)(request)
-			}()
-
-			if result != nil {
-				response := Response{
-					Base:   *base,
-					Result: result,
-				}
-				return &response, err
+	found := s.registry().findTarget(ctx, request.Method)
+	if found != nil {
+		start := time.Now()
+		span := startSpan("Target", ctx, request)
+		request.span = span
+		result, err = invoke(request, found(db))
+		s.endSpan(span, err)
+		context, method := s.requestLabels("Target", ctx, request.Method)
+		s.metrics.observeHandler(
+			"Target", context, method, time.Since(start))
+
+		if result != nil {
+			response := Response{
+				Base:   *base,
+				Result: result,
 			}
-			return nil, err
+			return &response, err
 		}
+		return nil, err
 	}
 	return nil, errMethodNotMatch
 }
@@ -139,28 +218,28 @@ func (s *Server) findAndExecuteHandlerInSource(
 	var result interface{}
 	var err error
 
-	if s.registersSource[ctx] != nil {
-		found := s.registersSource[ctx][request.Method]
-
-		if found != nil {
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						err = fmt.Errorf("%v", r)
-					}
-				}()
-				result, err = found(request)
-			}()
-
-			if result != nil {
-				response := Response{
-					Base:   *base,
-					Result: result,
-				}
-				return &response, err
+	found := s.registry().findSource(ctx, request.Method)
+	if found == nil && s.NotFound != nil {
+		found = s.NotFound
+	}
+	if found != nil {
+		start := time.Now()
+		span := startSpan("Source", ctx, request)
+		request.span = span
+		result, err = invoke(request, found)
+		s.endSpan(span, err)
+		context, method := s.requestLabels("Source", ctx, request.Method)
+		s.metrics.observeHandler(
+			"Source", context, method, time.Since(start))
+
+		if result != nil {
+			response := Response{
+				Base:   *base,
+				Result: result,
 			}
-			return nil, err
+			return &response, err
 		}
+		return nil, err
 	}
 	return nil, errMethodNotMatch
 }
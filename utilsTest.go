@@ -23,13 +23,18 @@ func startServerWithAddress(address string) (
 
 func startServerAndClient(t *testing.T) (
 	server *Server, conn net.Conn, err error) {
-	server, err = startServer()
-	if err != nil {
+	return startGivenServerAndClient(t, &Server{Address: address})
+}
+
+func startGivenServerAndClient(t *testing.T, given *Server) (
+	server *Server, conn net.Conn, err error) {
+	server = given
+	if err = server.Start(); err != nil {
 		t.Error(err)
 		return
 	}
 
-	conn, err = net.Dial("tcp", address)
+	conn, err = net.Dial("tcp", server.Address)
 	if err != nil {
 		t.Error(err)
 		server.Close()